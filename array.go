@@ -0,0 +1,269 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+var arrayType = reflect.TypeOf(Array{})
+
+// Array is a PHP array, an ordered map whose keys are integers or strings.
+// See http://php.net/manual/en/language.types.array.php for more detail.
+//
+// The keys are int64 or string values, and they are cast in the same way as PHP.
+// For example, the string "8" is cast into the integer 8, but "08" is not.
+//
+// Unlike maps, Array keeps the order of the insertion.
+// A Decoder decodes JSON objects and JSON arrays into Array keeping the order of the members,
+// and Array is encoded into a JSON array or a JSON object in the same way as PHP's json_encode.
+// The zero value for Array is an empty array ready to use.
+type Array struct {
+	keys   []interface{}
+	values []interface{}
+	index  map[interface{}]int // the positions of the keys
+	next   int64               // the next integer key for Append
+	hasInt bool                // the array has ever had integer keys
+}
+
+// NewArray returns a new list with the values.
+func NewArray(values ...interface{}) *Array {
+	a := new(Array)
+	for _, v := range values {
+		a.Append(v)
+	}
+	return a
+}
+
+// arrayKey casts the key into the integer or string key of PHP arrays.
+// See http://php.net/manual/en/language.types.array.php#language.types.array.syntax
+func arrayKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case string:
+		if n, ok := phpIntKey([]byte(k)); ok {
+			return n
+		}
+		return k
+	case int64:
+		return k
+	case int:
+		return int64(k)
+	case int8:
+		return int64(k)
+	case int16:
+		return int64(k)
+	case int32:
+		return int64(k)
+	case uint:
+		return int64(k)
+	case uint8:
+		return int64(k)
+	case uint16:
+		return int64(k)
+	case uint32:
+		return int64(k)
+	case uint64:
+		return int64(k)
+	case float32:
+		return int64(k)
+	case float64:
+		// Floats are also cast to integers, which means that the fractional part will be truncated.
+		return int64(k)
+	case bool:
+		// Bools are cast to integers, too.
+		if k {
+			return int64(1)
+		}
+		return int64(0)
+	case nil:
+		// Null will be cast to the empty string.
+		return ""
+	}
+	panic(fmt.Sprintf("phperjson: illegal offset type %T", key))
+}
+
+// Len returns the number of the elements of a.
+func (a *Array) Len() int {
+	return len(a.keys)
+}
+
+// Get returns the value for the key, and reports whether the key exists.
+// The key is cast in the same way as Set.
+func (a *Array) Get(key interface{}) (interface{}, bool) {
+	i, ok := a.index[arrayKey(key)]
+	if !ok {
+		return nil, false
+	}
+	return a.values[i], true
+}
+
+// Set sets the value for the key.
+// If the key already exists, the value is replaced keeping its position,
+// otherwise the key is added to the end.
+//
+// The key must be an integer, a string, a float, a bool or nil,
+// which are cast into an int64 or a string in the same way as PHP.
+// Set panics for other types of keys.
+func (a *Array) Set(key interface{}, value interface{}) {
+	k := arrayKey(key)
+	if i, ok := a.index[k]; ok {
+		a.values[i] = value
+		return
+	}
+	if a.index == nil {
+		a.index = make(map[interface{}]int)
+	}
+	if n, ok := k.(int64); ok && (!a.hasInt || n >= a.next) {
+		// PHP 8.3 and later use the max integer key + 1 for the next key even if it is negative.
+		a.next = n + 1
+		if n == math.MaxInt64 {
+			a.next = n
+		}
+		a.hasInt = true
+	}
+	a.index[k] = len(a.keys)
+	a.keys = append(a.keys, k)
+	a.values = append(a.values, value)
+}
+
+// Append adds the value to the end with the next integer key, as $a[] = $value in PHP.
+// Append panics if the next key is already used.
+func (a *Array) Append(value interface{}) {
+	if _, ok := a.index[a.next]; ok {
+		panic("phperjson: cannot add element to the array as the next element is already occupied")
+	}
+	a.Set(a.next, value)
+}
+
+// Delete deletes the value for the key.
+// It doesn't change the next integer key, as unset in PHP.
+func (a *Array) Delete(key interface{}) {
+	k := arrayKey(key)
+	i, ok := a.index[k]
+	if !ok {
+		return
+	}
+	delete(a.index, k)
+	a.keys = append(a.keys[:i], a.keys[i+1:]...)
+	a.values = append(a.values[:i], a.values[i+1:]...)
+	for j := i; j < len(a.keys); j++ {
+		a.index[a.keys[j]] = j
+	}
+}
+
+// Reset removes all the elements of a.
+func (a *Array) Reset() {
+	*a = Array{}
+}
+
+// Keys returns the keys of a in order.
+// Each key is an int64 or a string.
+func (a *Array) Keys() []interface{} {
+	return append([]interface{}(nil), a.keys...)
+}
+
+// List returns the values of a in order, in the same way as PHP's array_values.
+func (a *Array) List() []interface{} {
+	return append([]interface{}{}, a.values...)
+}
+
+// Map returns the elements of a as a map.
+// The integer keys are converted into strings, in the same way as JSON objects.
+func (a *Array) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(a.keys))
+	for i, k := range a.keys {
+		m[arrayKeyString(k)] = a.values[i]
+	}
+	return m
+}
+
+// Range calls f for each key and value of a in order.
+// If f returns false, Range stops the iteration.
+func (a *Array) Range(f func(key, value interface{}) bool) {
+	for i, k := range a.keys {
+		if !f(k, a.values[i]) {
+			return
+		}
+	}
+}
+
+// IsList reports whether the keys of a are the sequence 0, 1, 2, ... in order,
+// in the same way as PHP's array_is_list.
+func (a *Array) IsList() bool {
+	for i, k := range a.keys {
+		if n, ok := k.(int64); !ok || n != int64(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON implements Marshaler.
+// A list is encoded into a JSON array, and other arrays are encoded into JSON objects,
+// in the same way as PHP's json_encode.
+func (a Array) MarshalJSON() ([]byte, error) {
+	return Marshal(a)
+}
+
+// arrayKeyString returns the key of PHP arrays as a string.
+func arrayKeyString(key interface{}) string {
+	if n, ok := key.(int64); ok {
+		return strconv.FormatInt(n, 10)
+	}
+	return key.(string)
+}
+
+// UseArray causes the Decoder to unmarshal JSON objects and JSON arrays into an interface{}
+// as a *Array instead of as a map[string]interface{} or a []interface{}.
+func (dec *Decoder) UseArray() {
+	dec.useArray = true
+}
+
+// objectArray decodes the JSON object at dec.off into the Array a.
+func (dec *Decoder) objectArray(a *Array) error {
+	useArray := dec.useArray
+	dec.useArray = true
+	defer func() { dec.useArray = useArray }()
+
+	a.Reset()
+	dec.off++ // '{'
+	for {
+		key, _, ok := dec.objectKey()
+		if !ok {
+			break
+		}
+		dec.pushKey(key)
+		v, err := dec.valueInterface()
+		if err != nil {
+			return err
+		}
+		dec.popPath()
+		a.Set(string(key), v)
+	}
+	return nil
+}
+
+// arrayArray decodes the JSON array at dec.off into the Array a.
+func (dec *Decoder) arrayArray(a *Array) error {
+	useArray := dec.useArray
+	dec.useArray = true
+	defer func() { dec.useArray = useArray }()
+
+	a.Reset()
+	dec.off++ // '['
+	for dec.arrayElem() {
+		dec.pushIndex(a.Len())
+		v, err := dec.valueInterface()
+		if err != nil {
+			return err
+		}
+		dec.popPath()
+		a.Append(v)
+	}
+	return nil
+}
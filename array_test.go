@@ -0,0 +1,220 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArrayKey(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{"8", int64(8)},
+		{"-8", int64(-8)},
+		{"0", int64(0)},
+		{"08", "08"},
+		{"-0", "-0"},
+		{"+8", "+8"},
+		{"8.0", "8.0"},
+		{" 8", " 8"},
+		{"", ""},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"9223372036854775808", "9223372036854775808"},
+		{8, int64(8)},
+		{uint8(8), int64(8)},
+		{8.7, int64(8)},
+		{true, int64(1)},
+		{false, int64(0)},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := arrayKey(tt.in); got != tt.out {
+			t.Errorf("arrayKey(%#v): got %#v, want %#v", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestArray(t *testing.T) {
+	var a Array
+	a.Set("b", 1)
+	a.Set("8", 2)
+	a.Append(3)
+	a.Set(8, 4)
+	a.Set("08", 5)
+
+	if got, want := a.Keys(), []interface{}{"b", int64(8), int64(9), "08"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %#v, want %#v", got, want)
+	}
+	if got, want := a.List(), []interface{}{1, 4, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %#v, want %#v", got, want)
+	}
+	if got, want := a.Map(), map[string]interface{}{"b": 1, "8": 4, "9": 3, "08": 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Map: got %#v, want %#v", got, want)
+	}
+	if v, ok := a.Get(9.5); !ok || v != 3 {
+		t.Errorf("Get(9.5): got %#v, %t, want 3, true", v, ok)
+	}
+	if _, ok := a.Get("09"); ok {
+		t.Error(`Get("09"): want not ok`)
+	}
+
+	// unset doesn't reset the next key.
+	a.Delete("9")
+	a.Delete("missing")
+	a.Append(6)
+	if got, want := a.Keys(), []interface{}{"b", int64(8), "08", int64(10)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys after Delete: got %#v, want %#v", got, want)
+	}
+	if v, ok := a.Get(10); !ok || v != 6 {
+		t.Errorf("Get(10): got %#v, %t, want 6, true", v, ok)
+	}
+
+	// negative keys
+	var b Array
+	b.Set(-5, "a")
+	b.Append("b")
+	if got, want := b.Keys(), []interface{}{int64(-5), int64(-4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %#v, want %#v", got, want)
+	}
+}
+
+func TestArrayIsList(t *testing.T) {
+	if !NewArray().IsList() {
+		t.Error("empty array: want list")
+	}
+	a := NewArray("a", "b")
+	if !a.IsList() {
+		t.Error("NewArray: want list")
+	}
+	a.Delete(0)
+	if a.IsList() {
+		t.Error("after Delete(0): want not list")
+	}
+
+	var b Array
+	b.Set(1, "b")
+	b.Set(0, "a")
+	if b.IsList() {
+		t.Error("unordered keys: want not list")
+	}
+}
+
+func TestArrayMarshal(t *testing.T) {
+	var obj Array
+	obj.Set("b", 1)
+	obj.Set("a", NewArray(true, nil))
+	obj.Set(3, "x")
+
+	var sparse Array
+	sparse.Set(0, "a")
+	sparse.Set(2, "c")
+
+	tests := []struct {
+		in  interface{}
+		out string
+	}{
+		{NewArray(), `[]`},
+		{NewArray(1, "two", 3.5), `[1,"two",3.5]`},
+		{&obj, `{"b":1,"a":[true,null],"3":"x"}`},
+		{obj, `{"b":1,"a":[true,null],"3":"x"}`},
+		{&sparse, `{"0":"a","2":"c"}`},
+		{struct{ A *Array }{}, `{"A":null}`},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(tt.in)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.in, err)
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("Marshal(%#v): got %s, want %s", tt.in, b, tt.out)
+		}
+	}
+}
+
+func TestArrayUnmarshal(t *testing.T) {
+	tests := []string{
+		`[]`,
+		`[1,"two",3.5,true,null]`,
+		`{"b":1,"a":2,"10":3}`,
+		`{"0":"a","2":"c"}`,
+		`{"1":"b","0":"a"}`,
+		`{"x":{"z":1,"y":2},"w":[{"v":null}]}`,
+	}
+	for _, in := range tests {
+		var a Array
+		if err := Unmarshal([]byte(in), &a); err != nil {
+			t.Errorf("Unmarshal(%s): %v", in, err)
+			continue
+		}
+		b, err := Marshal(a)
+		if err != nil {
+			t.Errorf("Marshal(%s): %v", in, err)
+			continue
+		}
+		if string(b) != in {
+			t.Errorf("round trip: got %s, want %s", b, in)
+		}
+	}
+
+	// normalized keys
+	var a Array
+	if err := Unmarshal([]byte(`{"8":"a","08":"b","a":"c","8":"d"}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.Keys(), []interface{}{int64(8), "08", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %#v, want %#v", got, want)
+	}
+	if got, want := a.List(), []interface{}{"d", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %#v, want %#v", got, want)
+	}
+
+	// scalars are wrapped into arrays.
+	var s struct {
+		A Array
+		B *Array
+	}
+	if err := Unmarshal([]byte(`{"A":"foo","B":{"b":1}}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.A.Keys(), []interface{}{int64(0)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %#v, want %#v", got, want)
+	}
+	if v, _ := s.A.Get(0); v != "foo" {
+		t.Errorf("A[0]: got %#v, want %#v", v, "foo")
+	}
+	if v, _ := s.B.Get("b"); v != 1.0 {
+		t.Errorf("B[b]: got %#v, want %#v", v, 1.0)
+	}
+}
+
+func TestDecoderUseArray(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"b":[1,{"d":2,"c":3}],"a":"x"}`))
+	dec.UseArray()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	a, ok := v.(*Array)
+	if !ok {
+		t.Fatalf("got %T, want *Array", v)
+	}
+	if got, want := a.Keys(), []interface{}{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %#v, want %#v", got, want)
+	}
+	b, _ := a.Get("b")
+	list, ok := b.(*Array)
+	if !ok || !list.IsList() || list.Len() != 2 {
+		t.Fatalf("b: got %#v, want a list with 2 elements", b)
+	}
+	c, _ := list.Get(1)
+	if got, want := c.(*Array).Keys(), []interface{}{"d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %#v, want %#v", got, want)
+	}
+}
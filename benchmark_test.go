@@ -15,8 +15,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -233,3 +235,56 @@ func BenchmarkUnmapped(b *testing.B) {
 		})
 	})
 }
+
+// wideStruct returns a struct type with n fields and a JSON object for it.
+func wideStruct(n int) (reflect.Type, []byte) {
+	types := []reflect.Type{
+		reflect.TypeOf(""),
+		reflect.TypeOf(int64(0)),
+		reflect.TypeOf(float64(0)),
+		reflect.TypeOf(false),
+	}
+	values := []string{`"value"`, `42`, `3.14`, `true`}
+	fields := make([]reflect.StructField, 0, n)
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i := 0; i < n; i++ {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: types[i%len(types)],
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:"field_%d"`, i)),
+		})
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `"field_%d":%s`, i, values[i%len(values)])
+	}
+	buf.WriteByte('}')
+	return reflect.StructOf(fields), buf.Bytes()
+}
+
+func BenchmarkUnmarshalWideStruct(b *testing.B) {
+	typ, data := wideStruct(128)
+	b.Run("json", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			v := reflect.New(typ).Interface()
+			for pb.Next() {
+				if err := json.Unmarshal(data, v); err != nil {
+					b.Fatal("Unmarshal:", err)
+				}
+			}
+		})
+		b.SetBytes(int64(len(data)))
+	})
+	b.Run("phper-json", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			v := reflect.New(typ).Interface()
+			for pb.Next() {
+				if err := Unmarshal(data, v); err != nil {
+					b.Fatal("Unmarshal:", err)
+				}
+			}
+		})
+		b.SetBytes(int64(len(data)))
+	})
+}
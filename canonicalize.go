@@ -0,0 +1,249 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DecodeFlag is a set of the options of Canonicalize, which mirror the flags of PHP's json_decode.
+// The values are same as the JSON_* constants of PHP.
+// See https://www.php.net/manual/en/json.constants.php for more detail.
+type DecodeFlag int
+
+const (
+	// DecodeObjectAsArray decodes JSON objects into PHP arrays instead of stdClass objects,
+	// in the same way as JSON_OBJECT_AS_ARRAY or json_decode($json, true).
+	DecodeObjectAsArray DecodeFlag = 1
+
+	// DecodeBigIntAsString decodes the integers that overflow int64 into strings instead of floats,
+	// in the same way as JSON_BIGINT_AS_STRING.
+	DecodeBigIntAsString DecodeFlag = 2
+
+	// DecodeInvalidUTF8Ignore drops invalid UTF-8 bytes in strings,
+	// in the same way as JSON_INVALID_UTF8_IGNORE.
+	DecodeInvalidUTF8Ignore DecodeFlag = 0x100000
+
+	// DecodeInvalidUTF8Substitute replaces invalid UTF-8 bytes in strings with U+FFFD,
+	// in the same way as JSON_INVALID_UTF8_SUBSTITUTE.
+	DecodeInvalidUTF8Substitute DecodeFlag = 0x200000
+)
+
+// phpMaxDepth is the default depth of PHP's json_decode and json_encode.
+const phpMaxDepth = 512
+
+// Canonicalize re-encodes the JSON value data in the same way as PHP's
+// json_encode(json_decode($data, false, 512, $decodeFlags), $encodeFlags).
+// Use DecodeObjectAsArray for json_decode($data, true).
+// It is useful for computing the same hash of JSON values as PHP.
+//
+// The output is the same bytes as PHP's one, so EncodePHPCompatible is always on.
+// JSON objects are decoded into PHP arrays or objects keeping the order of their members,
+// and the last one of the duplicated keys wins.
+// PHP arrays are encoded into JSON arrays if their keys are 0, 1, 2, ...,
+// so {"0":"a","1":"b"} becomes ["a","b"] with DecodeObjectAsArray.
+// The integers are kept as they are, and the other numbers are reformatted as PHP's floats.
+//
+// Canonicalize returns a *SyntaxError if data is not valid JSON,
+// and a *DecodeError if json_decode fails,
+// for example invalid UTF-8 strings, unpaired UTF-16 surrogates or too deep nesting.
+func Canonicalize(data []byte, decodeFlags DecodeFlag, encodeFlags EncodeFlag) ([]byte, error) {
+	if !json.Valid(data) {
+		// encoding/json reports the syntax error.
+		var raw RawMessage
+		return nil, json.Unmarshal(data, &raw)
+	}
+	dec := &Decoder{
+		data:   data,
+		limits: Limits{MaxDepth: phpMaxDepth},
+	}
+	if err := dec.checkLimits(); err != nil {
+		return nil, err
+	}
+	v, err := dec.phpValue(decodeFlags)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalFlags(v, encodeFlags|EncodePHPCompatible)
+}
+
+// phpObject is a stdClass object of PHP.
+// It is always encoded into a JSON object.
+type phpObject Array
+
+var phpObjectType = reflect.TypeOf(phpObject{})
+
+// phpObjectEncoder encodes phpObject in the same way as PHP's json_encode.
+func phpObjectEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	a := Array(v.Interface().(phpObject))
+	e.phpArray(&a, true)
+}
+
+// phpValue decodes the next JSON value in the same way as PHP's json_decode.
+// It returns nil, bool, int64, float64, string, *Array or *phpObject.
+func (dec *Decoder) phpValue(flags DecodeFlag) (interface{}, error) {
+	dec.skipSpaces()
+	switch dec.data[dec.off] {
+	case '{':
+		return dec.phpObjectValue(flags)
+	case '[':
+		a := new(Array)
+		dec.off++ // '['
+		for dec.arrayElem() {
+			dec.pushIndex(a.Len())
+			v, err := dec.phpValue(flags)
+			if err != nil {
+				return nil, err
+			}
+			dec.popPath()
+			a.Append(v)
+		}
+		return a, nil
+	default:
+		start := dec.off
+		dec.skipLiteral()
+		return dec.phpLiteral(dec.data[start:dec.off], flags, start)
+	}
+}
+
+// phpObjectValue decodes the JSON object at dec.off into a PHP array or a PHP object.
+func (dec *Decoder) phpObjectValue(flags DecodeFlag) (interface{}, error) {
+	a := new(Array)
+	dec.off++ // '{'
+	for {
+		dec.skipSpaces()
+		if dec.data[dec.off] == ',' {
+			dec.off++
+			dec.skipSpaces()
+		}
+		if dec.data[dec.off] == '}' {
+			dec.off++
+			break
+		}
+		start := dec.off
+		dec.skipString()
+		key, err := dec.phpString(dec.data[start:dec.off], flags, start)
+		if err != nil {
+			return nil, err
+		}
+		if flags&DecodeObjectAsArray == 0 && strings.HasPrefix(key, "\x00") {
+			// PHP reserves the property names starting with NUL for private and protected properties.
+			return nil, dec.withErrorContext(fmt.Errorf("phperjson: invalid property name %q", key), start)
+		}
+		dec.skipSpaces()
+		dec.off++ // ':'
+
+		dec.pushKey([]byte(key))
+		v, err := dec.phpValue(flags)
+		if err != nil {
+			return nil, err
+		}
+		dec.popPath()
+		a.Set(key, v)
+	}
+	if flags&DecodeObjectAsArray == 0 {
+		return (*phpObject)(a), nil
+	}
+	return a, nil
+}
+
+// phpLiteral decodes the JSON literal item in the same way as PHP's json_decode.
+// off is the offset of item in dec.data.
+func (dec *Decoder) phpLiteral(item []byte, flags DecodeFlag, off int) (interface{}, error) {
+	switch c := item[0]; c {
+	case 'n': // null
+		return nil, nil
+	case 't', 'f': // true, false
+		return c == 't', nil
+	case '"': // string
+		return dec.phpString(item, flags, off)
+	default: // number
+		return phpNumber(string(item), flags), nil
+	}
+}
+
+// phpNumber converts the number literal s into int64, float64 or string
+// in the same way as PHP's json_decode.
+// Only DecodeBigIntAsString of flags affects the result.
+func phpNumber(s string, flags DecodeFlag) interface{} {
+	if !strings.ContainsAny(s, ".eE") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if flags&DecodeBigIntAsString != 0 {
+			return s
+		}
+	}
+	// the numbers out of the range of float64 become ±Inf,
+	// and json_encode rejects them.
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// phpString decodes the JSON string literal item in the same way as PHP's json_decode.
+// off is the offset of item in dec.data.
+func (dec *Decoder) phpString(item []byte, flags DecodeFlag, off int) (string, error) {
+	invalid := false
+	for i := 1; i < len(item)-1; i++ {
+		switch c := item[i]; {
+		case c == '\\':
+			if item[i+1] != 'u' {
+				i++
+				continue
+			}
+			r := getu4(item[i:])
+			i += 5
+			if !utf16.IsSurrogate(r) {
+				continue
+			}
+			// unquote replaces unpaired surrogates with U+FFFD, but PHP rejects them.
+			if r < 0xDC00 {
+				if r2 := getu4(item[i+1:]); 0xDC00 <= r2 && r2 < 0xE000 {
+					i += 6
+					continue
+				}
+			}
+			return "", dec.withErrorContext(fmt.Errorf("phperjson: single unpaired UTF-16 surrogate in string %s", item), off)
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(item[i : len(item)-1])
+			if r == utf8.RuneError && size == 1 {
+				invalid = true
+				continue
+			}
+			i += size - 1
+		}
+	}
+
+	if invalid {
+		switch {
+		case flags&DecodeInvalidUTF8Substitute != 0:
+			// unquote replaces each invalid byte with U+FFFD.
+		case flags&DecodeInvalidUTF8Ignore != 0:
+			valid := make([]byte, 0, len(item))
+			for i := 0; i < len(item); {
+				r, size := utf8.DecodeRune(item[i:])
+				if r != utf8.RuneError || size != 1 {
+					valid = append(valid, item[i:i+size]...)
+				}
+				i += size
+			}
+			item = valid
+		default:
+			return "", dec.withErrorContext(&InvalidUTF8Error{S: string(item[1 : len(item)-1])}, off)
+		}
+	}
+
+	s, ok := unquote(item)
+	if !ok {
+		return "", dec.withErrorContext(fmt.Errorf("phperjson: invalid string literal %s", item), off)
+	}
+	return s, nil
+}
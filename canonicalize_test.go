@@ -0,0 +1,78 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		in          string
+		decodeFlags DecodeFlag
+		encodeFlags EncodeFlag
+		out         string
+		err         string
+	}{
+		// arrays and objects
+		{in: ` { "b" : 1 , "a" : [ ] } `, decodeFlags: DecodeObjectAsArray, out: `{"b":1,"a":[]}`},
+		{in: `{}`, decodeFlags: DecodeObjectAsArray, out: `[]`},
+		{in: `{}`, out: `{}`},
+		{in: `{"0":"a","1":"b"}`, decodeFlags: DecodeObjectAsArray, out: `["a","b"]`},
+		{in: `{"0":"a","1":"b"}`, out: `{"0":"a","1":"b"}`},
+		{in: `{"1":"b","0":"a"}`, decodeFlags: DecodeObjectAsArray, out: `{"1":"b","0":"a"}`},
+		{in: `{"a":1,"b":2,"a":3}`, decodeFlags: DecodeObjectAsArray, out: `{"a":3,"b":2}`},
+		{in: `{"8":1,"08":2,"-0":3,"8":4}`, decodeFlags: DecodeObjectAsArray, out: `{"8":4,"08":2,"-0":3}`},
+		{in: `[{"0":1},{}]`, decodeFlags: DecodeObjectAsArray, encodeFlags: EncodeForceObject, out: `{"0":{"0":1},"1":{}}`},
+		{in: `{"a":{},"b":[]}`, encodeFlags: EncodePrettyPrint, out: "{\n    \"a\": {},\n    \"b\": []\n}"},
+		{in: `{"\u0000a":1}`, decodeFlags: DecodeObjectAsArray, out: `{"\u0000a":1}`},
+		{in: `{"\u0000a":1}`, err: `invalid property name "\x00a"`},
+
+		// numbers
+		{in: `[0,-0,1.0,1e2,0.1,1e20,1E-7,-0.0,9223372036854775807,-9223372036854775808]`, out: `[0,0,1,100,0.1,1.0e+20,1.0e-7,-0,9223372036854775807,-9223372036854775808]`},
+		{in: `[1.0,1e2]`, encodeFlags: EncodePreserveZeroFraction, out: `[1.0,100.0]`},
+		{in: `12345678901234567890`, out: `1.2345678901234567e+19`},
+		{in: `12345678901234567890`, decodeFlags: DecodeBigIntAsString, out: `"12345678901234567890"`},
+		{in: `12345678901234567890.0`, decodeFlags: DecodeBigIntAsString, out: `1.2345678901234567e+19`},
+		{in: `1e400`, err: "json: unsupported value: +Inf"},
+		{in: `[1e400,1]`, encodeFlags: EncodePartialOutputOnError, out: `[0,1]`, err: "json: unsupported value: +Inf"},
+		{in: `["1","1.50","a"]`, encodeFlags: EncodeNumericCheck, out: `[1,1.5,"a"]`},
+
+		// strings
+		{in: `"a/b\u00e9<\"'&>"`, out: `"a\/b\u00e9<\"'&>"`},
+		{in: `"a\/bé"`, encodeFlags: EncodeUnescapedSlashes | EncodeUnescapedUnicode, out: `"a/bé"`},
+		{in: `"\ud83d\ude00"`, out: `"\ud83d\ude00"`},
+		{in: `"\ud83d\ude00"`, encodeFlags: EncodeUnescapedUnicode, out: `"😀"`},
+		{in: `"\u2028"`, encodeFlags: EncodeUnescapedUnicode, out: `"\u2028"`},
+		{in: `"<'&\""`, encodeFlags: EncodeHexTag | EncodeHexApos | EncodeHexAmp | EncodeHexQuot, out: `"\u003C\u0027\u0026\u0022"`},
+		{in: `"\ud83d"`, err: `single unpaired UTF-16 surrogate in string "\ud83d"`},
+		{in: `["a","\ude00b"]`, err: `phperjson: [1]: phperjson: single unpaired UTF-16 surrogate in string "\ude00b"`},
+		{in: "{\"a\":\"\xff\"}", err: "phperjson: a: json: invalid UTF-8 in string: \"\\xff\""},
+		{in: "\"a\xffb\"", decodeFlags: DecodeInvalidUTF8Ignore, out: `"ab"`},
+		{in: "\"a\xffb\"", decodeFlags: DecodeInvalidUTF8Substitute, out: `"a\ufffdb"`},
+		{in: "\"a\xffb\"", decodeFlags: DecodeInvalidUTF8Ignore | DecodeInvalidUTF8Substitute, out: `"a\ufffdb"`},
+
+		// errors
+		{in: `{`, err: "unexpected end of JSON input"},
+		{in: strings.Repeat("[", phpMaxDepth) + strings.Repeat("]", phpMaxDepth), out: strings.Repeat("[", phpMaxDepth) + strings.Repeat("]", phpMaxDepth)},
+		{in: strings.Repeat("[", phpMaxDepth+1) + strings.Repeat("]", phpMaxDepth+1), err: "phperjson: exceeded max depth 512"},
+	}
+	for _, tt := range tests {
+		out, err := Canonicalize([]byte(tt.in), tt.decodeFlags, tt.encodeFlags)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("Canonicalize(%q): %v", tt.in, err)
+				continue
+			}
+		} else if err == nil || !strings.HasSuffix(err.Error(), tt.err) {
+			t.Errorf("Canonicalize(%q): error got %v, want %s", tt.in, err, tt.err)
+			continue
+		}
+		if string(out) != tt.out {
+			t.Errorf("Canonicalize(%q): got %s, want %s", tt.in, out, tt.out)
+		}
+	}
+}
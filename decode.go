@@ -17,15 +17,46 @@ import (
 	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
-var textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+var (
+	unmarshalerType     = reflect.TypeOf(new(Unmarshaler)).Elem()
+	textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+)
 
 // A Decoder reads and decodes JSON values from an input stream.
 type Decoder struct {
 	dec                   *json.Decoder
+	buf                   RawMessage // the buffer for the value read from dec
+	data                  []byte     // the value being decoded
+	off                   int        // next read offset in data
 	disallowUnknownFields bool
-	useNumber             bool
+	reportUnknownFields   bool
+	unknownFields         []*UnknownFieldError // the unknown fields skipped
+	numberMode            NumberMode
+	useArray              bool
+	allowLeadingNumeric   bool
+	zeroOnNull            bool
+	nullLike              NullLike
+	noJuggling            Juggling // the conversions disabled
+	coercionHook          func(c Coercion)
+	path                  []pathElem // the JSON path to the value being decoded
+	collectErrors         bool
+	objectToSlice         ObjectToSlicePolicy
+	limits                Limits
+	errors                []*DecodeError // the errors collected
+	lines                 *lineReader
+	limit                 *limitReader
+	base                  int64 // the input offset of data
+	line                  int   // the number of lines before data
+	lineStart             int64 // the input offset of the line containing the start of data
+	newlines              []int // the offsets of the newlines in data, nil until the first error
+	phpVersion            PHPVersion
+	floatToInt            FloatToIntPolicy
 	errorContext          struct { // provides context for type errors
 		Struct string
 		Field  string
@@ -34,10 +65,18 @@ type Decoder struct {
 
 // NewDecoder returns a new decoder that reads from r.
 func NewDecoder(r io.Reader) *Decoder {
-	dec := json.NewDecoder(r)
+	var lines *lineReader
+	if hasInputOffset {
+		lines = &lineReader{r: r}
+		r = lines
+	}
+	limit := &limitReader{r: r, n: -1}
+	dec := json.NewDecoder(limit)
 	dec.UseNumber()
 	return &Decoder{
-		dec: dec,
+		dec:   dec,
+		lines: lines,
+		limit: limit,
 	}
 }
 
@@ -47,16 +86,39 @@ func (dec *Decoder) Buffered() io.Reader {
 	return dec.dec.Buffered()
 }
 
-func (dec *Decoder) withErrorContext(err error) error {
-	if dec.errorContext.Struct != "" || dec.errorContext.Field != "" {
-		switch err := err.(type) {
-		case *UnmarshalTypeError:
+// withErrorContext adds the struct field, the JSON path and the position of the value being decoded to err.
+// off is the offset of the value in dec.data.
+func (dec *Decoder) withErrorContext(err error, off int) *DecodeError {
+	offset, line, column := dec.position(off)
+	if err, ok := err.(*UnmarshalTypeError); ok {
+		err.Offset = offset
+		if dec.errorContext.Struct != "" || dec.errorContext.Field != "" {
 			err.Struct = dec.errorContext.Struct
 			err.Field = dec.errorContext.Field
-			return err
 		}
 	}
-	return err
+	return &DecodeError{
+		Path:   dec.pathString(),
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		Err:    err,
+	}
+}
+
+// saveError records err and returns nil, if dec collects errors.
+// Otherwise it returns err as it is.
+// off is the offset of the value in dec.data.
+func (dec *Decoder) saveError(err error, off int) error {
+	if !dec.collectErrors {
+		return err
+	}
+	decErr, ok := err.(*DecodeError)
+	if !ok {
+		decErr = dec.withErrorContext(err, off)
+	}
+	dec.errors = append(dec.errors, decErr)
+	return nil
 }
 
 // from the encoding/json package.
@@ -131,767 +193,1735 @@ func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, encoding.TextUnm
 
 // Decode reads the next JSON-encoded value from its input and stores it in the value pointed to by v.
 func (dec *Decoder) Decode(v interface{}) error {
-	var iv interface{}
-	if err := dec.dec.Decode(&iv); err != nil {
+	if err := dec.read(); err != nil {
 		return err
 	}
+	if dec.lines != nil {
+		dec.base = inputOffset(dec.dec) - int64(len(dec.buf))
+		dec.line, dec.lineStart = dec.lines.lineAt(dec.base)
+	}
+	return dec.unmarshal(dec.buf, v)
+}
+
+// unmarshal decodes the valid JSON value data into v.
+func (dec *Decoder) unmarshal(data []byte, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
 	}
-	return dec.decode(iv, rv)
+	dec.data = data
+	dec.off = 0
+	dec.newlines = nil
+	dec.path = dec.path[:0]
+	dec.errors = nil
+	dec.unknownFields = nil
+	if err := dec.checkLimits(); err != nil {
+		dec.data = nil
+		return err
+	}
+	err := dec.value(rv)
+	dec.data = nil
+	dec.newlines = nil
+	dec.path = dec.path[:0]
+	if err == nil && len(dec.errors) > 0 {
+		err = &DecodeErrors{Errors: dec.errors}
+		dec.errors = nil
+	}
+	return err
+}
+
+// value decodes the next JSON value from dec.data into v.
+// If v is invalid, the value is skipped.
+func (dec *Decoder) value(v reflect.Value) error {
+	switch {
+	case !v.IsValid():
+	case v.Kind() == reflect.Struct && optionalTypes[v.Type()]:
+		return dec.optional(v)
+	case v.Kind() == reflect.Ptr && optionalTypes[v.Type().Elem()]:
+		return dec.optionalPtr(v)
+	}
+	dec.skipSpaces()
+	start := dec.off
+	depth := len(dec.path)
+	var err error
+	switch dec.data[dec.off] {
+	case '{':
+		if !v.IsValid() {
+			dec.skip()
+			return nil
+		}
+		err = dec.object(v)
+	case '[':
+		if !v.IsValid() {
+			dec.skip()
+			return nil
+		}
+		err = dec.array(v)
+	default:
+		dec.skipLiteral()
+		if !v.IsValid() {
+			return nil
+		}
+		err = dec.literalStore(dec.data[start:dec.off], v)
+	}
+	if err != nil && dec.collectErrors {
+		// skip the rest of the value, and continue decoding.
+		dec.path = dec.path[:depth]
+		dec.off = start
+		dec.skip()
+		return dec.saveError(err, start)
+	}
+	return err
+}
+
+// A decoderFunc decodes the next JSON value from dec.data into v.
+type decoderFunc func(dec *Decoder, v reflect.Value) error
+
+// typeDecoder returns the decoderFunc for the type t.
+// It returns the specialized one for the primitive types,
+// which doesn't need to look for unmarshalers and to switch on the kind of t for each value.
+func typeDecoder(t reflect.Type) decoderFunc {
+	pt := reflect.PtrTo(t)
+	if pt.Implements(unmarshalerType) || pt.Implements(textUnmarshalerType) || pt.Implements(scannerType) {
+		return (*Decoder).value
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return stringDecoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intDecoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintDecoder
+	case reflect.Float32, reflect.Float64:
+		return floatDecoder
+	case reflect.Bool:
+		return boolDecoder
+	}
+	return (*Decoder).value
+}
+
+// stringDecoder decodes JSON strings into v.
+// Other types of JSON values fall back to (*Decoder).value.
+func stringDecoder(dec *Decoder, v reflect.Value) error {
+	dec.skipSpaces()
+	if dec.data[dec.off] != '"' {
+		return dec.value(v)
+	}
+	start := dec.off
+	dec.skipString()
+	s, ok := unquoteBytes(dec.data[start:dec.off])
+	if !ok {
+		return dec.withErrorContext(fmt.Errorf("phperjson: invalid string literal %s", dec.data[start:dec.off]), start)
+	}
+	v.SetString(string(s))
+	return nil
+}
+
+// intDecoder decodes JSON numbers into v.
+// Other types of JSON values fall back to (*Decoder).value.
+func intDecoder(dec *Decoder, v reflect.Value) error {
+	dec.skipSpaces()
+	start := dec.off
+	if c := dec.data[start]; c == '-' || ('0' <= c && c <= '9') {
+		dec.skipLiteral()
+		if n, err := dec.parseInt(dec.data[start:dec.off], v.Type()); err == nil {
+			v.SetInt(n)
+			return nil
+		}
+		dec.off = start
+	}
+	return dec.value(v)
+}
+
+// uintDecoder decodes JSON numbers into v.
+// Other types of JSON values fall back to (*Decoder).value.
+func uintDecoder(dec *Decoder, v reflect.Value) error {
+	dec.skipSpaces()
+	start := dec.off
+	if c := dec.data[start]; '0' <= c && c <= '9' {
+		dec.skipLiteral()
+		if n, err := dec.parseUint(dec.data[start:dec.off], v.Type()); err == nil {
+			v.SetUint(n)
+			return nil
+		}
+		dec.off = start
+	}
+	return dec.value(v)
+}
+
+// floatDecoder decodes JSON numbers into v.
+// Other types of JSON values fall back to (*Decoder).value.
+func floatDecoder(dec *Decoder, v reflect.Value) error {
+	dec.skipSpaces()
+	start := dec.off
+	if c := dec.data[start]; c == '-' || ('0' <= c && c <= '9') {
+		dec.skipLiteral()
+		n, err := strconv.ParseFloat(string(dec.data[start:dec.off]), v.Type().Bits())
+		if err == nil && !v.OverflowFloat(n) {
+			v.SetFloat(n)
+			return nil
+		}
+		dec.off = start
+	}
+	return dec.value(v)
+}
+
+// boolDecoder decodes JSON booleans into v.
+// Other types of JSON values fall back to (*Decoder).value.
+func boolDecoder(dec *Decoder, v reflect.Value) error {
+	dec.skipSpaces()
+	switch dec.data[dec.off] {
+	case 't':
+		dec.off += len("true")
+		v.SetBool(true)
+		return nil
+	case 'f':
+		dec.off += len("false")
+		v.SetBool(false)
+		return nil
+	}
+	return dec.value(v)
 }
 
-func (dec *Decoder) decode(in interface{}, out reflect.Value) error {
-	if !out.IsValid() {
+// object decodes the JSON object at dec.off into v.
+func (dec *Decoder) object(v reflect.Value) error {
+	start := dec.off
+	u, ut, pv := indirect(v, false)
+	if u != nil {
+		dec.skip()
+		if err := u.UnmarshalJSON(dec.data[start:dec.off]); err != nil {
+			return dec.withErrorContext(err, start)
+		}
 		return nil
 	}
+	if ut != nil {
+		return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: v.Type()}, start)
+	}
+
+	v = pv
+	if v.Type() == arrayType {
+		return dec.objectArray(v.Addr().Interface().(*Array))
+	}
+	switch v.Kind() {
+	default:
+		return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: v.Type()}, start)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: v.Type()}, start)
+		}
+		oi, err := dec.valueInterface()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(oi))
+	case reflect.Map:
+		if err := dec.checkMapKey(v.Type(), start); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		var mapElem reflect.Value
+		dec.off++ // '{'
+		for {
+			key, keyOff, ok := dec.objectKey()
+			if !ok {
+				break
+			}
+			elemType := v.Type().Elem()
+			if !mapElem.IsValid() {
+				mapElem = reflect.New(elemType).Elem()
+			} else {
+				mapElem.Set(reflect.Zero(elemType))
+			}
+			subv := mapElem
+			dec.pushKey(key)
+			errs := len(dec.errors)
+			if err := dec.value(subv); err != nil {
+				return err
+			}
+			if len(dec.errors) > errs {
+				// the element failed, and the error is collected.
+				dec.popPath()
+				continue
+			}
+			kv, err := dec.mapKey(string(key), v.Type().Key(), keyOff)
+			if err != nil {
+				if err := dec.saveError(err, keyOff); err != nil {
+					return err
+				}
+				dec.popPath()
+				continue
+			}
+			dec.popPath()
+			v.SetMapIndex(kv, subv)
+		}
+	case reflect.Struct:
+		fields, err := dec.cachedFields(v.Type(), start)
+		if err != nil {
+			return err
+		}
+		dec.off++ // '{'
+		for {
+			key, keyOff, ok := dec.objectKey()
+			if !ok {
+				break
+			}
+			dec.pushKey(key)
+			errorContext := dec.errorContext
+			subv, f, err := dec.structField(v, fields, key, keyOff)
+			if err != nil {
+				if err := dec.saveError(err, keyOff); err != nil {
+					return err
+				}
+			}
+			if f == nil {
+				dec.skip()
+				dec.popPath()
+				continue
+			}
+			err = dec.field(f, subv)
+			dec.errorContext = errorContext
+			if err != nil {
+				return err
+			}
+			dec.popPath()
+		}
+	case reflect.Bool:
+		// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
+		// When converting to boolean, the following values are considered FALSE:
+		// an array with zero elements
+		if !dec.allowJuggling(JuggleObjectToBool) {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: v.Type()}, start)
+		}
+		v.SetBool(!dec.isEmpty())
+		dec.skip()
+		dec.coerce(JuggleObjectToBool, dec.data[start:dec.off], v.Type())
+	case reflect.Slice, reflect.Array:
+		// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
+		// the keys of the object are interpreted as indexes of the slice.
+		if !dec.allowJuggling(JuggleObjectToArray) {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: v.Type()}, start)
+		}
+		if err := dec.objectSlice(v); err != nil {
+			return err
+		}
+		dec.coerce(JuggleObjectToArray, dec.data[start:dec.off], v.Type())
+	}
+	return nil
+}
+
+// objectSlice decodes the JSON object at dec.off into the slice or the array v,
+// following the policy of dec for the keys of the object.
+func (dec *Decoder) objectSlice(v reflect.Value) error {
+	isSlice := v.Kind() == reflect.Slice
+	if isSlice {
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		} else {
+			v.SetLen(0)
+		}
+	} else {
+		// fill zero
+		zero := reflect.Zero(v.Type().Elem())
+		for i := 0; i < v.Len(); i++ {
+			v.Index(i).Set(zero)
+		}
+	}
+
+	n := 0    // the number of the members of the object
+	dec.off++ // '{'
+	for {
+		key, keyOff, ok := dec.objectKey()
+		if !ok {
+			break
+		}
+
+		i, err := dec.objectSliceIndex(v, key, n)
+		n++
+		if err != nil {
+			dec.pushKey(key)
+			if err := dec.saveError(dec.withErrorContext(err, keyOff), keyOff); err != nil {
+				return err
+			}
+			dec.skip()
+			dec.popPath()
+			continue
+		}
+		if i >= v.Len() && !isSlice {
+			// Ran out of fixed array: skip.
+			dec.skip()
+			continue
+		}
+		if i >= v.Len() {
+			growSlice(v, i+1)
+		}
+		if dec.objectToSlice == ObjectToSliceCompact {
+			dec.pushKey(key)
+		} else {
+			dec.pushIndex(i)
+		}
+		if err := dec.value(v.Index(i)); err != nil {
+			return err
+		}
+		dec.popPath()
+	}
+	return nil
+}
+
+// objectSliceIndex returns the index of the slice or the array v
+// for the n-th member of the object with the key.
+func (dec *Decoder) objectSliceIndex(v reflect.Value, key []byte, n int) (int, error) {
+	if dec.objectToSlice == ObjectToSliceCompact {
+		// PHP flavored https://www.php.net/manual/en/function.array-values.php
+		// the keys are ignored, and the values are indexed in the order of the object.
+		return n, nil
+	}
+
+	i, ok := phpIntKey(key)
+	if !ok || i > int64(maxInt) || i < 0 {
+		return 0, &UnmarshalTypeError{Value: "object key " + strconv.Quote(string(key)), Type: v.Type()}
+	}
+	if dec.objectToSlice == ObjectToSliceError && i != int64(n) {
+		return 0, &UnmarshalTypeError{Value: "object key " + strconv.Quote(string(key)), Type: v.Type()}
+	}
+	if max := dec.maxSliceIndex(); max >= 0 && i > int64(max) && v.Kind() == reflect.Slice {
+		return 0, &LimitError{Limit: "slice index", Max: max}
+	}
+	if v.Kind() == reflect.Slice && !canAllocSlice(v.Type(), int(i)+1) {
+		// reflect.MakeSlice panics for the slices that the Go runtime can't allocate.
+		return 0, &UnmarshalTypeError{Value: "object key " + strconv.Quote(string(key)), Type: v.Type()}
+	}
+	return int(i), nil
+}
 
-	u, ut, pv := indirect(out, in == nil)
+// array decodes the JSON array at dec.off into v.
+func (dec *Decoder) array(v reflect.Value) error {
+	start := dec.off
+	u, ut, pv := indirect(v, false)
 	if u != nil {
-		data, err := json.Marshal(in)
+		dec.skip()
+		if err := u.UnmarshalJSON(dec.data[start:dec.off]); err != nil {
+			return dec.withErrorContext(err, start)
+		}
+		return nil
+	}
+	if ut != nil {
+		return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: v.Type()}, start)
+	}
+
+	v = pv
+	if v.Type() == arrayType {
+		return dec.arrayArray(v.Addr().Interface().(*Array))
+	}
+	switch v.Kind() {
+	default:
+		return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: v.Type()}, start)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: v.Type()}, start)
+		}
+		ai, err := dec.valueInterface()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(ai))
+	case reflect.Array:
+		i := 0
+		dec.off++ // '['
+		for dec.arrayElem() {
+			if i < v.Len() {
+				dec.pushIndex(i)
+				if err := dec.value(v.Index(i)); err != nil {
+					return err
+				}
+				dec.popPath()
+			} else {
+				// Ran out of fixed array: skip.
+				dec.skip()
+			}
+			i++
+		}
+		if i < v.Len() {
+			// Zero the rest.
+			zero := reflect.Zero(v.Type().Elem())
+			for ; i < v.Len(); i++ {
+				v.Index(i).Set(zero)
+			}
+		}
+	case reflect.Slice:
+		i := 0
+		dec.off++ // '['
+		for dec.arrayElem() {
+			// Grow slice if necessary
+			if i >= v.Cap() {
+				newcap := v.Cap() * 2
+				if newcap < 4 {
+					newcap = 4
+				}
+				newv := reflect.MakeSlice(v.Type(), v.Len(), newcap)
+				reflect.Copy(newv, v)
+				v.Set(newv)
+			}
+			if i >= v.Len() {
+				v.SetLen(i + 1)
+			}
+			dec.pushIndex(i)
+			if err := dec.value(v.Index(i)); err != nil {
+				return err
+			}
+			dec.popPath()
+			i++
+		}
+		if i < v.Len() {
+			v.SetLen(i)
+		}
+		if i == 0 && v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+	case reflect.Bool:
+		// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
+		// When converting to boolean, the following values are considered FALSE:
+		// an array with zero elements
+		if !dec.allowJuggling(JuggleArrayToBool) {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: v.Type()}, start)
+		}
+		v.SetBool(!dec.isEmpty())
+		dec.skip()
+		dec.coerce(JuggleArrayToBool, dec.data[start:dec.off], v.Type())
+	case reflect.Map:
+		// PHP flavored
+		// PHP doesn't not distinguish JSON arrays from JSON objects.
+		if !dec.allowJuggling(JuggleArrayToObject) {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: v.Type()}, start)
+		}
+		if err := dec.checkMapKey(v.Type(), start); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		var mapElem reflect.Value
+		i := 0
+		dec.off++ // '['
+		for dec.arrayElem() {
+			elemOff := dec.off
+			// decode value
+			elemType := v.Type().Elem()
+			if !mapElem.IsValid() {
+				mapElem = reflect.New(elemType).Elem()
+			} else {
+				mapElem.Set(reflect.Zero(elemType))
+			}
+			subv := mapElem
+			dec.pushIndex(i)
+			errs := len(dec.errors)
+			if err := dec.value(subv); err != nil {
+				return err
+			}
+			if len(dec.errors) > errs {
+				// the element failed, and the error is collected.
+				dec.popPath()
+				i++
+				continue
+			}
+			// decode key
+			kv, err := dec.mapKey(strconv.Itoa(i), v.Type().Key(), elemOff)
+			if err != nil {
+				if err := dec.saveError(err, elemOff); err != nil {
+					return err
+				}
+				dec.popPath()
+				i++
+				continue
+			}
+			dec.popPath()
+			v.SetMapIndex(kv, subv)
+			i++
+		}
+		dec.coerce(JuggleArrayToObject, dec.data[start:dec.off], v.Type())
+	case reflect.Struct:
+		// PHP flavored
+		// PHP doesn't not distinguish JSON arrays from JSON objects.
+		if !dec.allowJuggling(JuggleArrayToObject) {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: v.Type()}, start)
+		}
+		fields, err := dec.cachedFields(v.Type(), start)
 		if err != nil {
 			return err
 		}
-		return u.UnmarshalJSON(data)
+		i := 0
+		dec.off++ // '['
+		for dec.arrayElem() {
+			// Figure out field corresponding to key.
+			dec.pushIndex(i)
+			errorContext := dec.errorContext
+			subv, f, err := dec.structField(v, fields, []byte(strconv.Itoa(i)), dec.off)
+			if err != nil {
+				if err := dec.saveError(err, dec.off); err != nil {
+					return err
+				}
+			}
+			i++
+			if f == nil {
+				dec.skip()
+				dec.popPath()
+				continue
+			}
+			err = dec.field(f, subv)
+			dec.errorContext = errorContext
+			if err != nil {
+				return err
+			}
+			dec.popPath()
+		}
+		dec.coerce(JuggleArrayToObject, dec.data[start:dec.off], v.Type())
+	}
+	return nil
+}
+
+// literalStore decodes the JSON literal item into v.
+func (dec *Decoder) literalStore(item []byte, v reflect.Value) (err error) {
+	start := dec.off - len(item) // the offset of item in dec.data
+	if dec.nullLike != NullLikeNone && dec.isNullLike(item, v) {
+		item = nullLiteral
+	}
+	isNull := item[0] == 'n'
+	u, ut, pv := indirect(v, isNull)
+	if u != nil {
+		if err := u.UnmarshalJSON(item); err != nil {
+			return dec.withErrorContext(err, start)
+		}
+		return nil
 	}
 	if ut != nil {
-		switch v := in.(type) {
+		switch item[0] {
+		case 't', 'f':
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: v.Type()}, start)
+		case '"':
+			s, ok := unquoteBytes(item)
+			if !ok {
+				return dec.withErrorContext(fmt.Errorf("phperjson: invalid string literal %s", item), start)
+			}
+			if err := ut.UnmarshalText(s); err != nil {
+				return dec.withErrorContext(err, start)
+			}
+			return nil
+		default:
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: v.Type()}, start)
+		}
+	}
+
+	v = pv
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(scannerType) {
+		return dec.scannerStore(item, v)
+	}
+	j := literalJuggling(item[0], v)
+	if !dec.allowJuggling(j) {
+		switch item[0] {
+		case 't', 'f':
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: v.Type()}, start)
+		case '"':
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
 		default:
-			return dec.withErrorContext(&UnmarshalTypeError{Type: out.Type()})
-		case nil:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "null", Type: out.Type()})
-		case bool:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: out.Type()})
-		case Number:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: out.Type()})
-		case string:
-			return ut.UnmarshalText([]byte(v))
-		}
-	}
-
-	out = pv
-	switch v := in.(type) {
-	case nil:
-		switch out.Kind() {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: v.Type()}, start)
+		}
+	}
+	if j != JuggleNone && dec.coercionHook != nil {
+		// report the conversion only if it succeeds.
+		defer func() {
+			if err == nil {
+				dec.coerce(j, item, v.Type())
+			}
+		}()
+	}
+
+	switch c := item[0]; c {
+	case 'n': // null
+		switch v.Kind() {
 		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
-			out.Set(reflect.Zero(out.Type()))
+			v.Set(reflect.Zero(v.Type()))
+		default:
+			if dec.zeroOnNull {
+				v.Set(reflect.Zero(v.Type()))
+			}
 			// otherwise, ignore null for primitives
 		}
-	case bool:
-		switch out.Kind() {
+	case 't', 'f': // true, false
+		value := c == 't'
+		switch v.Kind() {
 		default:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: out.Type()})
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: v.Type()}, start)
 		case reflect.Bool:
-			out.SetBool(v)
+			v.SetBool(value)
 		case reflect.Interface:
-			if out.NumMethod() == 0 {
-				out.Set(reflect.ValueOf(v))
-			} else {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: out.Type()})
+			if v.NumMethod() != 0 {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "bool", Type: v.Type()}, start)
 			}
+			v.Set(reflect.ValueOf(value))
 		case reflect.String:
 			// PHP flavored http://php.net/manual/en/language.types.string.php#language.types.string.casting
 			// A boolean TRUE value is converted to the string "1".
 			// Boolean FALSE is converted to "" (the empty string).
 			// This allows conversion back and forth between boolean and string values.
-			if v {
-				out.SetString("1")
+			if value {
+				v.SetString("1")
 			} else {
-				out.SetString("")
+				v.SetString("")
 			}
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			// PHP flavored http://php.net/manual/en/language.types.integer.php#language.types.integer.casting
 			// FALSE will yield 0 (zero), and TRUE will yield 1 (one).
-			if v {
-				out.SetInt(1)
+			if value {
+				v.SetInt(1)
 			} else {
-				out.SetInt(0)
+				v.SetInt(0)
 			}
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 			// PHP flavored http://php.net/manual/en/language.types.string.php#language.types.string.casting
 			// FALSE will yield 0 (zero), and TRUE will yield 1 (one).
-			if v {
-				out.SetUint(1)
+			if value {
+				v.SetUint(1)
 			} else {
-				out.SetUint(0)
+				v.SetUint(0)
 			}
 		case reflect.Float32, reflect.Float64:
 			// PHP flavored http://php.net/manual/en/language.types.float.php#language.types.float.casting
 			// FALSE will yield 0 (zero), and TRUE will yield 1 (one).
-			if v {
-				out.SetFloat(1)
+			if value {
+				v.SetFloat(1)
 			} else {
-				out.SetFloat(0)
+				v.SetFloat(0)
 			}
-		case reflect.Slice:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if out.Cap() == 0 {
-				newout := reflect.MakeSlice(out.Type(), 1, 1)
-				out.Set(newout)
-			}
-			out.SetLen(1)
-			if err := dec.decode(v, out.Index(0)); err != nil {
-				return err
+		case reflect.Slice, reflect.Map, reflect.Struct:
+			return dec.scalarArrayStore(item, v)
+		}
+	case '"': // string
+		s, ok := unquoteBytes(item)
+		if !ok {
+			return dec.withErrorContext(fmt.Errorf("phperjson: invalid string literal %s", item), start)
+		}
+		switch v.Kind() {
+		default:
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+		case reflect.String:
+			v.SetString(string(s))
+		case reflect.Interface:
+			if v.NumMethod() != 0 {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
 			}
-		case reflect.Map:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if err := dec.decode(map[string]interface{}{"0": v}, out); err != nil {
-				return err
+			v.Set(reflect.ValueOf(string(s)))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if len(s) == 0 {
+				v.SetInt(0)
+				break
 			}
-		case reflect.Struct:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if err := dec.decode(map[string]interface{}{"0": v}, out); err != nil {
-				return err
+			num, ok := dec.numericString(s)
+			if !ok {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+			}
+			n, err := dec.parseInt(num, v.Type())
+			if err != nil {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if len(s) == 0 {
+				v.SetUint(0)
+				break
+			}
+			num, ok := dec.numericString(s)
+			if !ok {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+			}
+			n, err := dec.parseUint(num, v.Type())
+			if err != nil {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			if len(s) == 0 {
+				v.SetFloat(0)
+				break
+			}
+			num, ok := dec.numericString(s)
+			if !ok {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+			}
+			n, err := strconv.ParseFloat(string(num), v.Type().Bits())
+			if err != nil || v.OverflowFloat(n) {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: v.Type()}, start)
+			}
+			v.SetFloat(n)
+		case reflect.Bool:
+			// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
+			// When converting to boolean, the following values are considered FALSE:
+			// the empty string, and the string "0"
+			v.SetBool(!(len(s) == 0 || (len(s) == 1 && s[0] == '0')))
+		case reflect.Slice:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				b := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+				n, err := base64.StdEncoding.Decode(b, s)
+				if err != nil {
+					return dec.withErrorContext(err, start)
+				}
+				v.SetBytes(b[:n])
+				break
 			}
+			return dec.scalarArrayStore(item, v)
+		case reflect.Map, reflect.Struct:
+			return dec.scalarArrayStore(item, v)
 		}
-	case Number:
-		switch out.Kind() {
+	default: // number
+		switch v.Kind() {
 		default:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: out.Type()})
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: v.Type()}, start)
 		case reflect.String:
-			out.SetString(string(v))
+			v.SetString(string(item))
 		case reflect.Interface:
-			n, err := dec.convertNumber(string(v))
+			n, err := dec.convertNumber(string(item), start)
 			if err != nil {
 				return err
 			}
-			if out.NumMethod() != 0 {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: out.Type()})
+			if v.NumMethod() != 0 {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: v.Type()}, start)
 			}
-			out.Set(reflect.ValueOf(n))
+			v.Set(reflect.ValueOf(n))
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			n, err := parseInt(string(v), out.Type())
+			n, err := dec.parseInt(item, v.Type())
 			if err != nil {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + string(v), Type: out.Type()})
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + string(item), Type: v.Type()}, start)
 			}
-			out.SetInt(n)
+			v.SetInt(n)
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			n, err := parseUint(string(v), out.Type())
+			n, err := dec.parseUint(item, v.Type())
 			if err != nil {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + string(v), Type: out.Type()})
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + string(item), Type: v.Type()}, start)
 			}
-			out.SetUint(n)
+			v.SetUint(n)
 		case reflect.Float32, reflect.Float64:
-			n, err := strconv.ParseFloat(string(v), out.Type().Bits())
-			if err != nil || out.OverflowFloat(n) {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + string(v), Type: out.Type()})
+			n, err := strconv.ParseFloat(string(item), v.Type().Bits())
+			if err != nil || v.OverflowFloat(n) {
+				return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + string(item), Type: v.Type()}, start)
 			}
-			out.SetFloat(n)
+			v.SetFloat(n)
 		case reflect.Bool:
 			// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
 			// the integer 0 (zero)
 			// the float 0.0 (zero)
-			n, err := strconv.ParseFloat(string(v), 64)
-			if err == nil && n == 0 {
-				out.SetBool(false)
-			} else {
-				out.SetBool(true)
-			}
-		case reflect.Slice:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if out.Cap() == 0 {
-				newout := reflect.MakeSlice(out.Type(), 1, 1)
-				out.Set(newout)
-			}
-			out.SetLen(1)
-			if err := dec.decode(v, out.Index(0)); err != nil {
+			n, err := strconv.ParseFloat(string(item), 64)
+			v.SetBool(!(err == nil && n == 0))
+		case reflect.Slice, reflect.Map, reflect.Struct:
+			return dec.scalarArrayStore(item, v)
+		}
+	}
+	return nil
+}
+
+// scalarArrayStore stores the JSON literal item into the slice, map or struct v
+// as an array with a single element with index zero.
+//
+// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
+// For any of the types integer, float, string, boolean and resource,
+// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
+// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
+func (dec *Decoder) scalarArrayStore(item []byte, v reflect.Value) error {
+	start := dec.off - len(item) // the offset of item in dec.data
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Cap() == 0 {
+			v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		}
+		v.SetLen(1)
+		return dec.literalStore(item, v.Index(0))
+	case reflect.Map:
+		if err := dec.checkMapKey(v.Type(), start); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		subv := reflect.New(v.Type().Elem()).Elem()
+		if err := dec.literalStore(item, subv); err != nil {
+			return err
+		}
+		kv, err := dec.mapKey("0", v.Type().Key(), start)
+		if err != nil {
+			return dec.saveError(err, start)
+		}
+		v.SetMapIndex(kv, subv)
+	case reflect.Struct:
+		if v.Type() == arrayType {
+			value, err := dec.literalInterface(item)
+			if err != nil {
 				return err
 			}
-		case reflect.Map:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if err := dec.decode(map[string]interface{}{"0": v}, out); err != nil {
-				return err
+			a := v.Addr().Interface().(*Array)
+			a.Reset()
+			a.Append(value)
+			return nil
+		}
+		fields, err := dec.cachedFields(v.Type(), start)
+		if err != nil {
+			return err
+		}
+		errorContext := dec.errorContext
+		subv, f, err := dec.structField(v, fields, []byte("0"), start)
+		if err != nil {
+			return dec.saveError(err, start)
+		}
+		if f != nil {
+			noJuggling, nullLike := dec.noJuggling, dec.nullLike
+			if f.juggle {
+				dec.noJuggling = f.noJuggling
 			}
-		case reflect.Struct:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if err := dec.decode(map[string]interface{}{"0": v}, out); err != nil {
-				return err
+			if f.null {
+				dec.nullLike = f.nullLike
 			}
+			err = dec.literalStore(item, subv)
+			dec.noJuggling, dec.nullLike = noJuggling, nullLike
 		}
-	case string:
-		switch out.Kind() {
-		default:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: out.Type()})
-		case reflect.String:
-			out.SetString(v)
-		case reflect.Interface:
-			if out.NumMethod() != 0 {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: out.Type()})
-			}
-			out.Set(reflect.ValueOf(v))
+		dec.errorContext = errorContext
+		return err
+	}
+	return nil
+}
+
+// checkMapKey checks that the map type t can be decoded from JSON objects.
+func (dec *Decoder) checkMapKey(t reflect.Type, off int) error {
+	// Map key must either have string kind, have an integer kind,
+	// or be an encoding.TextUnmarshaler.
+	kt := t.Key()
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !reflect.PtrTo(kt).Implements(textUnmarshalerType) {
+			return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: t}, off)
+		}
+	}
+	return nil
+}
+
+// mapKey converts the object key into a value of the map key type kt.
+// off is the offset of the key in dec.data.
+func (dec *Decoder) mapKey(key string, kt reflect.Type, off int) (reflect.Value, error) {
+	switch {
+	case kt.Kind() == reflect.String:
+		return reflect.ValueOf(key).Convert(kt), nil
+	case reflect.PtrTo(kt).Implements(textUnmarshalerType):
+		kv := reflect.New(kt)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, dec.withErrorContext(err, off)
+		}
+		return kv.Elem(), nil
+	default:
+		switch kt.Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			if v == "" {
-				out.SetInt(0)
-				break
-			}
-			n, err := parseInt(v, out.Type())
-			if err != nil {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: out.Type()})
+			n, err := strconv.ParseInt(key, 10, 64)
+			if err != nil || reflect.Zero(kt).OverflowInt(n) {
+				return reflect.Value{}, dec.withErrorContext(&UnmarshalTypeError{Value: "number " + key, Type: kt}, off)
 			}
-			out.SetInt(n)
+			return reflect.ValueOf(n).Convert(kt), nil
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			if v == "" {
-				out.SetUint(0)
-				break
-			}
-			n, err := parseUint(v, out.Type())
-			if err != nil {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: out.Type()})
-			}
-			out.SetUint(n)
-		case reflect.Float32, reflect.Float64:
-			if v == "" {
-				out.SetFloat(0)
-				break
-			}
-			n, err := strconv.ParseFloat(string(v), out.Type().Bits())
-			if err != nil || out.OverflowFloat(n) {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "string", Type: out.Type()})
+			n, err := strconv.ParseUint(key, 10, 64)
+			if err != nil || reflect.Zero(kt).OverflowUint(n) {
+				return reflect.Value{}, dec.withErrorContext(&UnmarshalTypeError{Value: "number " + key, Type: kt}, off)
 			}
-			out.SetFloat(n)
-		case reflect.Bool:
-			// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
-			// When converting to boolean, the following values are considered FALSE:
-			// the empty string, and the string "0"
-			if v == "" || v == "0" {
-				out.SetBool(false)
-			} else {
-				out.SetBool(true)
-			}
-		case reflect.Slice:
-			if out.Type().Elem().Kind() == reflect.Uint8 {
-				b, err := base64.StdEncoding.DecodeString(v)
-				if err != nil {
-					return err
-				}
-				out.SetBytes(b)
-				break
-			}
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if out.Cap() == 0 {
-				newout := reflect.MakeSlice(out.Type(), 1, 1)
-				out.Set(newout)
-			}
-			out.SetLen(1)
-			if err := dec.decode(v, out.Index(0)); err != nil {
-				return err
-			}
-		case reflect.Map:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if err := dec.decode(map[string]interface{}{"0": v}, out); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// For any of the types integer, float, string, boolean and resource,
-			// converting a value to an array results in an array with a single element with index zero and the value of the scalar which was converted.
-			// In other words, (array)$scalarValue is exactly the same as array($scalarValue).
-			if err := dec.decode(map[string]interface{}{"0": v}, out); err != nil {
-				return err
-			}
-		}
-	case []interface{}:
-		switch out.Kind() {
+			return reflect.ValueOf(n).Convert(kt), nil
 		default:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: out.Type()})
-		case reflect.Interface:
-			if out.NumMethod() == 0 {
-				out.Set(reflect.ValueOf(v))
-			} else {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "array", Type: out.Type()})
-			}
-		case reflect.Array:
-			l := len(v)
-			if out.Len() < l {
-				// Ran out of fixed array: skip.
-				l = out.Len()
-			}
-			var i int
-			for i = 0; i < l; i++ {
-				if err := dec.decode(v[i], out.Index(i)); err != nil {
-					return err
-				}
-			}
-			if i < out.Len() {
-				// Zero the rest.
-				zero := reflect.Zero(out.Type().Elem())
-				for ; i < out.Len(); i++ {
-					out.Index(i).Set(zero)
-				}
-			}
-		case reflect.Slice:
-			if len(v) == 0 {
-				out.Set(reflect.MakeSlice(out.Type(), 0, 0))
-				break
-			}
-			// Grow slice if necessary
-			if len(v) > out.Cap() {
-				newout := reflect.MakeSlice(out.Type(), len(v), len(v))
-				out.Set(newout)
-			}
-			out.SetLen(len(v))
-			for i, vv := range v {
-				if err := dec.decode(vv, out.Index(i)); err != nil {
-					return err
-				}
-			}
-		case reflect.Bool:
-			// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
-			// When converting to boolean, the following values are considered FALSE:
-			// an array with zero elements
-			if len(v) == 0 {
-				out.SetBool(false)
-			} else {
-				out.SetBool(true)
-			}
-		case reflect.Map:
-			// PHP flavored
-			// PHP doesn't not distinguish JSON arrays from JSON objects.
-			t := out.Type()
-			kt := t.Key()
-			// Map key must either have string kind, have an integer kind,
-			// or be an encoding.TextUnmarshaler.
-			switch kt.Kind() {
-			case reflect.String,
-				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			default:
-				if !reflect.PtrTo(kt).Implements(textUnmarshalerType) {
-					return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: out.Type()})
-				}
-			}
-			if out.IsNil() {
-				out.Set(reflect.MakeMap(t))
-			}
-			var mapElem reflect.Value
-			for i, vv := range v {
-				// decode value
-				elemType := out.Type().Elem()
-				if !mapElem.IsValid() {
-					mapElem = reflect.New(elemType).Elem()
-				} else {
-					mapElem.Set(reflect.Zero(elemType))
-				}
-				subv := mapElem
-				if err := dec.decode(vv, subv); err != nil {
-					return err
-				}
-				// decode key
-				var kv reflect.Value
-				switch {
-				case kt.Kind() == reflect.String:
-					kv = reflect.ValueOf(strconv.Itoa(i)).Convert(kt)
-				case reflect.PtrTo(kt).Implements(textUnmarshalerType):
-					kv = reflect.New(kt)
-					if err := dec.decode(strconv.Itoa(i), kv); err != nil {
-						return err
-					}
-					kv = kv.Elem()
-				default:
-					switch kt.Kind() {
-					case reflect.String:
-					case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-						if reflect.Zero(kt).OverflowInt(int64(i)) {
-							return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: kt})
-						}
-						kv = reflect.ValueOf(int64(i)).Convert(kt)
-					case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-						if reflect.Zero(kt).OverflowUint(uint64(i)) {
-							return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: kt})
-						}
-						kv = reflect.ValueOf(uint64(i)).Convert(kt)
-					default:
-						panic("json: Unexpected key type") // should never occur
-					}
-				}
-				out.SetMapIndex(kv, subv)
-			}
-		case reflect.Struct:
-			// PHP flavored
-			// PHP doesn't not distinguish JSON arrays from JSON objects.
-			for i, value := range v {
-				// Figure out field corresponding to key.
-				key := strconv.Itoa(i)
-				var subv reflect.Value
-				var f *field
-				fields := cachedTypeFields(out.Type())
-				for i := range fields {
-					ff := &fields[i]
-					if ff.name == key {
-						f = ff
-						break
-					}
-				}
-				if f != nil {
-					subv = out
-					for _, i := range f.index {
-						if subv.Kind() == reflect.Ptr {
-							if subv.IsNil() {
-								if !subv.CanSet() {
-									return fmt.Errorf("phperjson: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem())
-								}
-								subv.Set(reflect.New(subv.Type().Elem()))
-							}
-							subv = subv.Elem()
-						}
-						subv = subv.Field(i)
-					}
-					dec.errorContext.Struct = out.Type().Name()
-					dec.errorContext.Field = f.name
-				} else if dec.disallowUnknownFields {
-					return fmt.Errorf("json: unknown field %q", key)
-				}
-				err := dec.decode(value, subv)
-				dec.errorContext.Struct = ""
-				dec.errorContext.Field = ""
-				if err != nil {
-					return err
-				}
-			}
+			panic("json: Unexpected key type") // should never occur
 		}
-	case map[string]interface{}:
-		switch out.Kind() {
-		default:
-			return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: out.Type()})
-		case reflect.Interface:
-			if out.NumMethod() == 0 {
-				if dec.useNumber {
-					out.Set(reflect.ValueOf(v))
-				} else if converted, err := dec.convertNumber2Float64(v); err == nil {
-					out.Set(reflect.ValueOf(converted))
-				} else {
-					return err
-				}
-			} else {
-				return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: out.Type()})
-			}
-		case reflect.Map:
-			t := out.Type()
-			kt := t.Key()
-			if kt.Kind() == reflect.String && t.Elem().Kind() == reflect.Interface && out.Len() == 0 {
-				out.Set(reflect.ValueOf(v))
-				break
-			}
+	}
+}
 
-			// Map key must either have string kind, have an integer kind,
-			// or be an encoding.TextUnmarshaler.
-			switch kt.Kind() {
-			case reflect.String,
-				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			default:
-				if !reflect.PtrTo(kt).Implements(textUnmarshalerType) {
-					return dec.withErrorContext(&UnmarshalTypeError{Value: "object", Type: out.Type()})
-				}
+// cachedFields returns the fields of the struct type t.
+// It returns an error if the decoder struct tags of t are invalid.
+// off is the offset of the value in dec.data.
+func (dec *Decoder) cachedFields(t reflect.Type, off int) (structFields, error) {
+	fields := cachedTypeFields(t)
+	if fields.err != nil {
+		return structFields{}, dec.withErrorContext(fields.err, off)
+	}
+	return fields, nil
+}
+
+// structField returns the field of the struct v corresponding to key.
+// It returns nil field if v has no such field.
+// off is the offset of the key in dec.data.
+func (dec *Decoder) structField(v reflect.Value, fields structFields, key []byte, off int) (reflect.Value, *field, error) {
+	// Figure out field corresponding to key.
+	f := fields.byExactName[string(key)]
+	if f == nil {
+		var buf [32]byte
+		f = fields.byFoldedName[string(appendFoldedName(buf[:0], key))]
+		if f != nil && !f.equalFold(f.nameBytes, key) {
+			f = nil
+		}
+	}
+	if f == nil {
+		if dec.disallowUnknownFields || dec.reportUnknownFields {
+			err := &UnknownFieldError{
+				Key:    string(key),
+				Type:   v.Type(),
+				Path:   dec.pathString(),
+				Offset: dec.base + int64(off),
 			}
-			if out.IsNil() {
-				out.Set(reflect.MakeMap(t))
+			if dec.disallowUnknownFields {
+				return reflect.Value{}, nil, dec.withErrorContext(err, off)
 			}
-			var mapElem reflect.Value
-			for key, vv := range v {
-				elemType := out.Type().Elem()
-				if !mapElem.IsValid() {
-					mapElem = reflect.New(elemType).Elem()
-				} else {
-					mapElem.Set(reflect.Zero(elemType))
-				}
-				subv := mapElem
-				if err := dec.decode(vv, subv); err != nil {
-					return err
-				}
-				var kv reflect.Value
-				switch {
-				case kt.Kind() == reflect.String:
-					kv = reflect.ValueOf(key).Convert(kt)
-				case reflect.PtrTo(kt).Implements(textUnmarshalerType):
-					kv = reflect.New(kt)
-					if err := dec.decode(key, kv); err != nil {
-						return err
-					}
-					kv = kv.Elem()
-				default:
-					switch kt.Kind() {
-					case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-						n, err := strconv.ParseInt(key, 10, 64)
-						if err != nil || reflect.Zero(kt).OverflowInt(n) {
-							return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + key, Type: kt})
-						}
-						kv = reflect.ValueOf(n).Convert(kt)
-					case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-						n, err := strconv.ParseUint(key, 10, 64)
-						if err != nil || reflect.Zero(kt).OverflowUint(n) {
-							return dec.withErrorContext(&UnmarshalTypeError{Value: "number " + key, Type: kt})
-						}
-						kv = reflect.ValueOf(n).Convert(kt)
-					default:
-						panic("json: Unexpected key type") // should never occur
-					}
-				}
-				out.SetMapIndex(kv, subv)
-			}
-		case reflect.Struct:
-			for key, value := range v {
-				// Figure out field corresponding to key.
-				var subv reflect.Value
-				var f *field
-				fields := cachedTypeFields(out.Type())
-				for i := range fields {
-					ff := &fields[i]
-					if ff.name == key {
-						f = ff
-						break
-					}
-					if f == nil && ff.equalFold(ff.nameBytes, []byte(key)) {
-						f = ff
-					}
-				}
-				if f != nil {
-					subv = out
-					for _, i := range f.index {
-						if subv.Kind() == reflect.Ptr {
-							if subv.IsNil() {
-								if !subv.CanSet() {
-									return fmt.Errorf("phperjson: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem())
-								}
-								subv.Set(reflect.New(subv.Type().Elem()))
-							}
-							subv = subv.Elem()
-						}
-						subv = subv.Field(i)
+			dec.unknownFields = append(dec.unknownFields, err)
+		}
+		return reflect.Value{}, nil, nil
+	}
+
+	var subv reflect.Value
+	if !f.embedPtr {
+		subv = v.FieldByIndex(f.index)
+	} else {
+		subv = v
+		for _, i := range f.index {
+			if subv.Kind() == reflect.Ptr {
+				if subv.IsNil() {
+					if !subv.CanSet() {
+						err := fmt.Errorf("phperjson: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem())
+						return reflect.Value{}, nil, dec.withErrorContext(err, off)
 					}
-					dec.errorContext.Struct = out.Type().Name()
-					dec.errorContext.Field = f.name
-				} else if dec.disallowUnknownFields {
-					return fmt.Errorf("json: unknown field %q", key)
-				}
-				err := dec.decode(value, subv)
-				dec.errorContext.Struct = ""
-				dec.errorContext.Field = ""
-				if err != nil {
-					return err
+					subv.Set(reflect.New(subv.Type().Elem()))
 				}
+				subv = subv.Elem()
 			}
-		case reflect.Bool:
-			// PHP flavored http://php.net/manual/en/language.types.boolean.php#language.types.boolean.casting
-			// When converting to boolean, the following values are considered FALSE:
-			// an array with zero elements
-			if len(v) == 0 {
-				out.SetBool(false)
-			} else {
-				out.SetBool(true)
-			}
-		case reflect.Slice:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// check all keys are number, and find the max key.
-			max := -1
-			for key := range v {
-				i, err := strconv.ParseInt(key, 10, 0)
-				if err != nil {
-					return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: reflect.TypeOf("")})
-				}
-				if int(i) > max {
-					max = int(i)
-				}
-			}
-			// Grow slice if necessary
-			if max < 0 || max+1 > out.Cap() {
-				newout := reflect.MakeSlice(out.Type(), max+1, max+1)
-				out.Set(newout)
-			} else {
-				// fill zero
-				zero := reflect.Zero(out.Type().Elem())
-				for i := 0; i <= max; i++ {
-					out.Index(i).Set(zero)
-				}
-			}
-			out.SetLen(max + 1)
-			for key, vv := range v {
-				i, _ := strconv.ParseInt(key, 10, 0) // err has been already checked, so no need to check here.
-				if err := dec.decode(vv, out.Index(int(i))); err != nil {
-					return err
-				}
-			}
-		case reflect.Array:
-			// PHP flavored http://php.net/manual/en/language.types.array.php#language.types.array.casting
-			// fill zero
-			zero := reflect.Zero(out.Type().Elem())
-			for i := 0; i < out.Len(); i++ {
-				out.Index(i).Set(zero)
-			}
+			subv = subv.Field(i)
+		}
+	}
+	dec.errorContext.Struct = v.Type().Name()
+	dec.errorContext.Field = f.name
+	return subv, f, nil
+}
 
-			for key, vv := range v {
-				i, err := strconv.ParseInt(key, 10, 0)
-				if err != nil {
-					return dec.withErrorContext(&UnmarshalTypeError{Value: "number", Type: reflect.TypeOf("")})
-				}
-				if int(i) >= out.Len() {
-					continue
-				}
-				if err := dec.decode(vv, out.Index(int(i))); err != nil {
-					return err
-				}
-			}
+// field decodes the next JSON value into the struct field subv described by f.
+// The juggle tag and the null tag of f override the settings of dec while decoding.
+func (dec *Decoder) field(f *field, subv reflect.Value) error {
+	if !f.juggle && !f.null {
+		return f.decode(dec, subv)
+	}
+	noJuggling, nullLike := dec.noJuggling, dec.nullLike
+	if f.juggle {
+		dec.noJuggling = f.noJuggling
+	}
+	if f.null {
+		dec.nullLike = f.nullLike
+	}
+	err := f.decode(dec, subv)
+	dec.noJuggling, dec.nullLike = noJuggling, nullLike
+	return err
+}
+
+// maxSliceBytes is the max size of the slices that objectSlice allocates.
+// The Go runtime limits the heap addresses to 48 bits on 64-bit platforms.
+const maxSliceBytes = 1<<48 - 1
+
+// canAllocSlice reports whether the Go runtime can allocate the slice of the type t with the length n.
+func canAllocSlice(t reflect.Type, n int) bool {
+	size := uint64(t.Elem().Size())
+	if size == 0 {
+		size = 1
+	}
+	return uint64(n) <= maxSliceBytes/size && uint64(n)*size <= uint64(maxInt)
+}
+
+// growSlice extends the length of the slice v to n, with zero values.
+func growSlice(v reflect.Value, n int) {
+	if n > v.Cap() {
+		newcap := v.Cap() + v.Cap()/2
+		if newcap < n {
+			newcap = n
+		}
+		newv := reflect.MakeSlice(v.Type(), n, newcap)
+		reflect.Copy(newv, v)
+		v.Set(newv)
+		return
+	}
+	l := v.Len()
+	v.SetLen(n)
+	zero := reflect.Zero(v.Type().Elem())
+	for i := l; i < n; i++ {
+		v.Index(i).Set(zero)
+	}
+}
+
+// valueInterface decodes the next JSON value into interface{}.
+func (dec *Decoder) valueInterface() (interface{}, error) {
+	dec.skipSpaces()
+	switch dec.data[dec.off] {
+	case '{':
+		if dec.useArray {
+			a := new(Array)
+			return a, dec.objectArray(a)
 		}
+		return dec.objectInterface()
+	case '[':
+		if dec.useArray {
+			a := new(Array)
+			return a, dec.arrayArray(a)
+		}
+		return dec.arrayInterface()
 	default:
-		panic(fmt.Sprintf("unknown type: %v", reflect.TypeOf(v)))
+		start := dec.off
+		dec.skipLiteral()
+		return dec.literalInterface(dec.data[start:dec.off])
 	}
-	return nil
 }
 
-// convertNumber converts the number literal s to a float64 or a Number
-// depending on the setting of dec.useNumber.
-func (dec *Decoder) convertNumber(s string) (interface{}, error) {
-	if dec.useNumber {
+// objectInterface decodes the JSON object at dec.off into map[string]interface{}.
+func (dec *Decoder) objectInterface() (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	dec.off++ // '{'
+	for {
+		key, _, ok := dec.objectKey()
+		if !ok {
+			break
+		}
+		k := string(key)
+		dec.pushKey(key)
+		v, err := dec.valueInterface()
+		if err != nil {
+			return nil, err
+		}
+		dec.popPath()
+		m[k] = v
+	}
+	return m, nil
+}
+
+// arrayInterface decodes the JSON array at dec.off into []interface{}.
+func (dec *Decoder) arrayInterface() ([]interface{}, error) {
+	a := []interface{}{}
+	dec.off++ // '['
+	for dec.arrayElem() {
+		dec.pushIndex(len(a))
+		v, err := dec.valueInterface()
+		if err != nil {
+			return nil, err
+		}
+		dec.popPath()
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+// literalInterface decodes the JSON literal item into interface{}.
+func (dec *Decoder) literalInterface(item []byte) (interface{}, error) {
+	switch c := item[0]; c {
+	case 'n': // null
+		return nil, nil
+	case 't', 'f': // true, false
+		return c == 't', nil
+	case '"': // string
+		s, ok := unquote(item)
+		if !ok {
+			return nil, dec.withErrorContext(fmt.Errorf("phperjson: invalid string literal %s", item), dec.off-len(item))
+		}
+		return s, nil
+	default: // number
+		return dec.convertNumber(string(item), dec.off-len(item))
+	}
+}
+
+// convertNumber converts the number literal s to a float64, a Number, an int64 or a string
+// depending on the setting of dec.numberMode.
+func (dec *Decoder) convertNumber(s string, off int) (interface{}, error) {
+	switch dec.numberMode {
+	case NumberJSONNumber:
 		return Number(s), nil
+	case NumberPHP:
+		return phpNumber(s, 0), nil
+	case NumberPHPBigIntAsString:
+		return phpNumber(s, DecodeBigIntAsString), nil
 	}
 	f, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		return nil, &UnmarshalTypeError{Value: "number " + s, Type: reflect.TypeOf(0.0)}
+		return nil, dec.withErrorContext(&UnmarshalTypeError{Value: "number " + s, Type: reflect.TypeOf(0.0)}, off)
 	}
 	return f, nil
 }
 
-// convertNumber2Float64 converts number literals in v recursively.
-func (dec *Decoder) convertNumber2Float64(v interface{}) (interface{}, error) {
-	switch v := v.(type) {
-	case Number:
-		f, err := strconv.ParseFloat(string(v), 64)
-		if err != nil {
-			return nil, &UnmarshalTypeError{Value: "number " + string(v), Type: reflect.TypeOf(0.0)}
+// The following functions read tokens from dec.data.
+// dec.data must be a valid JSON value, it is already checked by encoding/json.
+
+// skipSpaces skips white spaces.
+func (dec *Decoder) skipSpaces() {
+	for dec.off < len(dec.data) {
+		switch dec.data[dec.off] {
+		case ' ', '\t', '\r', '\n':
+			dec.off++
+		default:
+			return
 		}
-		return f, nil
-	case []interface{}:
-		for i, vv := range v {
-			var err error
-			v[i], err = dec.convertNumber2Float64(vv)
-			if err != nil {
-				return nil, err
-			}
+	}
+}
+
+// skip skips the next JSON value.
+func (dec *Decoder) skip() {
+	dec.skipSpaces()
+	if c := dec.data[dec.off]; c != '{' && c != '[' {
+		dec.skipLiteral()
+		return
+	}
+	depth := 0
+	for {
+		switch dec.data[dec.off] {
+		case '"':
+			dec.skipString()
+		case '{', '[':
+			depth++
+			dec.off++
+		case '}', ']':
+			depth--
+			dec.off++
+		default:
+			dec.off++
 		}
-	case map[string]interface{}:
-		for key, vv := range v {
-			var err error
-			v[key], err = dec.convertNumber2Float64(vv)
-			if err != nil {
-				return nil, err
-			}
+		if depth == 0 {
+			return
 		}
 	}
-	return v, nil
 }
 
-// parse numbers as integer values.
-func parseInt(s string, t reflect.Type) (int64, error) {
-	n, err := strconv.ParseInt(s, 10, 64)
-	if err == nil {
-		if reflect.Zero(t).OverflowInt(n) {
-			return 0, errors.New("overflow")
+// skipLiteral skips the next string, number, true, false or null.
+func (dec *Decoder) skipLiteral() {
+	if dec.data[dec.off] == '"' {
+		dec.skipString()
+		return
+	}
+	for dec.off < len(dec.data) {
+		switch dec.data[dec.off] {
+		case ',', '}', ']', ' ', '\t', '\r', '\n':
+			return
 		}
-		return n, nil
+		dec.off++
 	}
+}
 
-	// PHP flavored http://php.net/manual/en/language.types.integer.php#language.types.integer.casting
-	// convert floating point numbers to integer
-	if t.Kind() == reflect.Int64 {
-		// Go's built-in float64 doesn't have enough precision
-		// to present int64 values.
-		// so we use math/big.Float here.
-		f, _, err := big.ParseFloat(s, 10, 64, big.ToZero)
-		if err != nil {
-			return 0, err
+// skipString skips the string literal at dec.off.
+func (dec *Decoder) skipString() {
+	dec.off++ // '"'
+	for {
+		switch dec.data[dec.off] {
+		case '\\':
+			dec.off += 2
+		case '"':
+			dec.off++
+			return
+		default:
+			dec.off++
 		}
-		n, acc := f.Int64()
-		if acc != big.Exact {
-			return 0, errors.New("overflow")
+	}
+}
+
+// objectKey reads the next key in the object and the following colon.
+// It returns the key and the offset of the key.
+// It reports false if it reaches the end of the object.
+func (dec *Decoder) objectKey() ([]byte, int, bool) {
+	dec.skipSpaces()
+	if dec.data[dec.off] == ',' {
+		dec.off++
+		dec.skipSpaces()
+	}
+	if dec.data[dec.off] == '}' {
+		dec.off++
+		return nil, 0, false
+	}
+	start := dec.off
+	dec.skipString()
+	key, ok := unquoteBytes(dec.data[start:dec.off])
+	if !ok {
+		// should never occur, because dec.data is valid.
+		panic("phperjson: invalid object key")
+	}
+	dec.skipSpaces()
+	dec.off++ // ':'
+	return key, start, true
+}
+
+// arrayElem reads a comma before the next element in the array.
+// It reports false if it reaches the end of the array.
+func (dec *Decoder) arrayElem() bool {
+	dec.skipSpaces()
+	if dec.data[dec.off] == ',' {
+		dec.off++
+		dec.skipSpaces()
+	}
+	if dec.data[dec.off] == ']' {
+		dec.off++
+		return false
+	}
+	return true
+}
+
+// isEmpty reports whether the object or the array at dec.off has no elements.
+func (dec *Decoder) isEmpty() bool {
+	i := dec.off + 1
+	for ; i < len(dec.data); i++ {
+		switch dec.data[i] {
+		case ' ', '\t', '\r', '\n':
+		case '}', ']':
+			return true
+		default:
+			return false
 		}
-		return n, nil
 	}
-	f, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0, err
+	return false
+}
+
+// from the encoding/json package.
+// getu4 decodes \uXXXX from the beginning of s, returning the hex value,
+// or it returns -1.
+func getu4(s []byte) rune {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return -1
+	}
+	var r rune
+	for _, c := range s[2:6] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return -1
+		}
+		r = r*16 + rune(c)
+	}
+	return r
+}
+
+// from the encoding/json package.
+// unquote converts a quoted JSON string literal s into an actual string t.
+// The rules are different than for Go, so cannot use strconv.Unquote.
+func unquote(s []byte) (t string, ok bool) {
+	s, ok = unquoteBytes(s)
+	t = string(s)
+	return
+}
+
+// from the encoding/json package.
+// unquoteBytes is like unquote, but returns a slice of s if no unquoting is needed.
+func unquoteBytes(s []byte) (t []byte, ok bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return
+	}
+	s = s[1 : len(s)-1]
+
+	// Check for unusual characters. If there are none,
+	// then no unquoting is needed, so return a slice of the
+	// original bytes.
+	r := 0
+	for r < len(s) {
+		c := s[r]
+		if c == '\\' || c == '"' || c < ' ' {
+			break
+		}
+		if c < utf8.RuneSelf {
+			r++
+			continue
+		}
+		rr, size := utf8.DecodeRune(s[r:])
+		if rr == utf8.RuneError && size == 1 {
+			break
+		}
+		r += size
+	}
+	if r == len(s) {
+		return s, true
+	}
+
+	b := make([]byte, len(s)+2*utf8.UTFMax)
+	w := copy(b, s[0:r])
+	for r < len(s) {
+		// Out of room? Can only happen if s is full of
+		// malformed UTF-8 and we're replacing each
+		// byte with RuneError.
+		if w >= len(b)-2*utf8.UTFMax {
+			nb := make([]byte, (len(b)+utf8.UTFMax)*2)
+			copy(nb, b[0:w])
+			b = nb
+		}
+		switch c := s[r]; {
+		case c == '\\':
+			r++
+			if r >= len(s) {
+				return
+			}
+			switch s[r] {
+			default:
+				return
+			case '"', '\\', '/', '\'':
+				b[w] = s[r]
+				r++
+				w++
+			case 'b':
+				b[w] = '\b'
+				r++
+				w++
+			case 'f':
+				b[w] = '\f'
+				r++
+				w++
+			case 'n':
+				b[w] = '\n'
+				r++
+				w++
+			case 'r':
+				b[w] = '\r'
+				r++
+				w++
+			case 't':
+				b[w] = '\t'
+				r++
+				w++
+			case 'u':
+				r--
+				rr := getu4(s[r:])
+				if rr < 0 {
+					return
+				}
+				r += 6
+				if utf16.IsSurrogate(rr) {
+					rr1 := getu4(s[r:])
+					if dec := utf16.DecodeRune(rr, rr1); dec != unicode.ReplacementChar {
+						// A valid pair; consume.
+						r += 6
+						w += utf8.EncodeRune(b[w:], dec)
+						break
+					}
+					// Invalid surrogate; fall back to replacement rune.
+					rr = unicode.ReplacementChar
+				}
+				w += utf8.EncodeRune(b[w:], rr)
+			}
+
+		// Quote, control characters are invalid.
+		case c == '"', c < ' ':
+			return
+
+		// ASCII
+		case c < utf8.RuneSelf:
+			b[w] = c
+			r++
+			w++
+
+		// Coerce to well-formed UTF-8.
+		default:
+			rr, size := utf8.DecodeRune(s[r:])
+			r += size
+			w += utf8.EncodeRune(b[w:], rr)
+		}
 	}
-	if f < math.MinInt64 || f > math.MaxInt64 {
-		return 0, errors.New("overflow")
+	return b[0:w], true
+}
+
+// parse numbers as integer values.
+func (dec *Decoder) parseInt(b []byte, t reflect.Type) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		// PHP flavored http://php.net/manual/en/language.types.integer.php#language.types.integer.casting
+		// convert floating point numbers to integer
+		i, err := dec.parseBigInt(b)
+		if err != nil {
+			return 0, err
+		}
+		if !i.IsInt64() {
+			return dec.intOverflow(b, i.Sign() < 0, t)
+		}
+		n = i.Int64()
 	}
-	n = int64(f)
 	if reflect.Zero(t).OverflowInt(n) {
-		return 0, errors.New("overflow")
+		if dec.floatToIntPolicy() == FloatToIntWrap {
+			return wrapInt(n, t.Bits()), nil
+		}
+		return dec.intOverflow(b, n < 0, t)
 	}
 	return n, nil
 }
 
-// parse numbers as unsigned integer values.
-func parseUint(s string, t reflect.Type) (uint64, error) {
-	n, err := strconv.ParseUint(s, 10, 64)
-	if err == nil {
-		if reflect.Zero(t).OverflowUint(n) {
-			return 0, errors.New("overflow")
+// intOverflow handles the number b out of the range of the integer type t.
+func (dec *Decoder) intOverflow(b []byte, negative bool, t reflect.Type) (int64, error) {
+	switch dec.floatToIntPolicy() {
+	case FloatToIntSaturate:
+		if negative {
+			return -1 << (t.Bits() - 1), nil
 		}
-		return n, nil
+		return 1<<(t.Bits()-1) - 1, nil
+	case FloatToIntWrap:
+		return wrapInt(phpWrapInt(string(b)), t.Bits()), nil
 	}
+	return 0, errors.New("overflow")
+}
 
-	// PHP flavored http://php.net/manual/en/language.types.integer.php#language.types.integer.casting
-	// convert floating point numbers to integer
-	if t.Kind() == reflect.Uint64 {
-		// Go's built-in float64 doesn't have enough precision
-		// to present int64 values.
-		// so we use math/big.Float here.
-		f, _, err := big.ParseFloat(s, 10, 64, big.ToZero)
+// parse numbers as unsigned integer values.
+func (dec *Decoder) parseUint(b []byte, t reflect.Type) (uint64, error) {
+	n, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		// PHP flavored http://php.net/manual/en/language.types.integer.php#language.types.integer.casting
+		// convert floating point numbers to integer
+		i, err := dec.parseBigInt(b)
 		if err != nil {
 			return 0, err
 		}
-		n, acc := f.Uint64()
-		if acc != big.Exact {
-			return 0, errors.New("overflow")
+		if !i.IsUint64() {
+			if i.IsInt64() && dec.floatToIntPolicy() == FloatToIntWrap {
+				return wrapUint(uint64(i.Int64()), t.Bits()), nil
+			}
+			return dec.uintOverflow(b, i.Sign() < 0, t)
 		}
-		return n, nil
+		n = i.Uint64()
 	}
-	f, err := strconv.ParseFloat(s, 64)
+	if reflect.Zero(t).OverflowUint(n) {
+		if dec.floatToIntPolicy() == FloatToIntWrap {
+			return wrapUint(n, t.Bits()), nil
+		}
+		return dec.uintOverflow(b, false, t)
+	}
+	return n, nil
+}
+
+// uintOverflow handles the number b out of the range of the unsigned integer type t.
+func (dec *Decoder) uintOverflow(b []byte, negative bool, t reflect.Type) (uint64, error) {
+	switch dec.floatToIntPolicy() {
+	case FloatToIntSaturate:
+		if negative {
+			return 0, nil
+		}
+		return wrapUint(math.MaxUint64, t.Bits()), nil
+	case FloatToIntWrap:
+		return wrapUint(uint64(phpWrapInt(string(b))), t.Bits()), nil
+	}
+	return 0, errors.New("overflow")
+}
+
+// parseBigInt parses the floating point number b and converts it into an integer
+// following the fractional part policy of dec.
+func (dec *Decoder) parseBigInt(b []byte) (*big.Int, error) {
+	policy := dec.floatToIntPolicy()
+	if policy == FloatToIntError && bytes.ContainsAny(b, ".eE") {
+		return nil, errors.New("floating point number")
+	}
+
+	// Go's built-in float64 doesn't have enough precision
+	// to present int64 values.
+	// so we use math/big.Float here.
+	f, _, err := big.ParseFloat(string(b), 10, 64, big.ToZero)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	if f < 0 || f > math.MaxUint64 {
-		return 0, errors.New("overflow")
+	if f.IsInf() {
+		return nil, errors.New("overflow")
 	}
-	n = uint64(f)
-	if reflect.Zero(t).OverflowUint(n) {
-		return 0, errors.New("overflow")
+	i, acc := f.Int(nil)
+	if acc != big.Exact && (policy == FloatToIntRejectFraction || policy == FloatToIntError) {
+		return nil, errors.New("fractional number")
 	}
-	return n, nil
+	return i, nil
+}
+
+// wrapInt wraps n around modulo 2^bits.
+func wrapInt(n int64, bits int) int64 {
+	shift := uint(64 - bits)
+	return n << shift >> shift
+}
+
+// wrapUint wraps n around modulo 2^bits.
+func wrapUint(n uint64, bits int) uint64 {
+	shift := uint(64 - bits)
+	return n << shift >> shift
+}
+
+// phpWrapInt converts the number s into an integer in the same way as PHP 7.
+// Out of range numbers wrap around modulo 2^64, and infinity yields zero.
+// See zend_dval_to_lval in https://github.com/php/php-src/blob/PHP-7.4/Zend/zend_operators.h
+func phpWrapInt(s string) int64 {
+	const twoPow64 = 1 << 64
+	d, _ := strconv.ParseFloat(s, 64)
+	if math.IsInf(d, 0) || math.IsNaN(d) {
+		return 0
+	}
+	dmod := math.Mod(d, twoPow64)
+	if dmod < 0 {
+		// we're going to make this number positive; call ourselves recursively
+		// if the result is -zero_point_five_or_less, which is not representable as zend_long
+		if dmod == -twoPow64/2 {
+			return math.MinInt64
+		}
+		dmod += twoPow64
+	}
+	if dmod > math.MaxInt64 {
+		dmod -= twoPow64
+	}
+	return int64(dmod)
+}
+
+// PHPVersion is a major version of PHP.
+// It selects the rules of the type juggling that a Decoder emulates.
+type PHPVersion int
+
+const (
+	// PHP7 emulates the type juggling of PHP 7.1 to 7.4.
+	//
+	// Numeric strings may have leading white spaces, but not trailing white spaces.
+	// Leading-numeric strings such as "123 " or "123abc" and non-numeric strings are rejected,
+	// unless AllowLeadingNumericStrings is set.
+	// With AllowLeadingNumericStrings, non-numeric strings are converted into zero.
+	// Numbers out of the range of integers wrap around (FloatToIntWrap).
+	PHP7 PHPVersion = 7
+
+	// PHP8 emulates the type juggling of PHP 8. It is the default.
+	//
+	// Numeric strings may have leading and trailing white spaces.
+	// Leading-numeric strings such as "123abc" are rejected, unless AllowLeadingNumericStrings is set.
+	// Non-numeric strings are always rejected.
+	// Numbers out of the range of integers are rejected (FloatToIntTruncate).
+	PHP8 PHPVersion = 8
+)
+
+// SetPHPVersion sets the version of PHP whose type juggling rules the Decoder emulates.
+// The default is PHP8.
+func (dec *Decoder) SetPHPVersion(v PHPVersion) {
+	dec.phpVersion = v
+}
+
+// ObjectToSlicePolicy specifies how a Decoder decodes JSON objects into slices and arrays.
+// PHP encodes arrays into JSON objects if their keys are not the sequence 0, 1, 2, ...,
+// such as {"0":"a","3":"b"}, {"-1":"x"} or {"a":1,"b":2}.
+type ObjectToSlicePolicy int
+
+const (
+	// ObjectToSliceSparse interprets the keys as the indexes, and fills the gaps with zero values.
+	// Keys other than non-negative integers cause errors for both slices and arrays,
+	// and the indexes beyond the length of arrays are skipped. It is the default.
+	ObjectToSliceSparse ObjectToSlicePolicy = iota
+
+	// ObjectToSliceError accepts only the objects whose keys are the sequence 0, 1, 2, ... in order,
+	// which PHP encodes with the JSON_FORCE_OBJECT option.
+	// Other keys cause errors.
+	ObjectToSliceError
+
+	// ObjectToSliceCompact ignores the keys, and stores the values in the order of the object,
+	// in the same way as PHP's array_values.
+	// It accepts any keys, including negative and non-numeric ones.
+	ObjectToSliceCompact
+)
+
+// SetObjectToSlicePolicy sets the policy for decoding JSON objects into slices and arrays.
+// Keys follow the PHP array key casting, so "8" is the index 8, while "08" is not an index.
+func (dec *Decoder) SetObjectToSlicePolicy(p ObjectToSlicePolicy) {
+	dec.objectToSlice = p
+}
+
+// FloatToIntPolicy specifies how a Decoder converts numbers into integers,
+// if they have fractional parts or are out of the range of the integer type.
+type FloatToIntPolicy int
+
+const (
+	// FloatToIntDefault follows the PHP version of the Decoder.
+	// It is FloatToIntWrap for PHP7, and FloatToIntTruncate for PHP8.
+	FloatToIntDefault FloatToIntPolicy = iota
+
+	// FloatToIntTruncate truncates fractional parts toward zero,
+	// and rejects numbers out of range.
+	FloatToIntTruncate
+
+	// FloatToIntRejectFraction rejects numbers that have fractional parts, such as 1.5,
+	// and numbers out of range.
+	// Floating point numbers without fractional parts, such as 1.0 or 1e3, are accepted.
+	FloatToIntRejectFraction
+
+	// FloatToIntSaturate truncates fractional parts toward zero,
+	// and clamps numbers out of range to the minimum or maximum value of the integer type.
+	FloatToIntSaturate
+
+	// FloatToIntWrap truncates fractional parts toward zero,
+	// and wraps numbers out of range around in the same way as PHP 7.
+	// Numbers are converted into 64-bit integers modulo 2^64,
+	// and then converted into the integer type modulo 2^bits.
+	FloatToIntWrap
+
+	// FloatToIntError rejects all floating point numbers, such as 1.5, 1.0 or 1e3,
+	// and numbers out of range.
+	FloatToIntError
+)
+
+// SetFloatToIntPolicy sets the policy for converting numbers into integers.
+// It applies to all signed and unsigned integer types,
+// and to numbers in both JSON numbers and JSON strings.
+func (dec *Decoder) SetFloatToIntPolicy(p FloatToIntPolicy) {
+	dec.floatToInt = p
+}
+
+// floatToIntPolicy returns the effective policy for converting numbers into integers.
+func (dec *Decoder) floatToIntPolicy() FloatToIntPolicy {
+	if dec.floatToInt != FloatToIntDefault {
+		return dec.floatToInt
+	}
+	if dec.phpVersion == PHP7 {
+		return FloatToIntWrap
+	}
+	return FloatToIntTruncate
 }
 
 // DisallowUnknownFields causes the Decoder to return an error
 // when the destination is a struct and the input contains object keys
 // which do not match any non-ignored, exported fields in the destination.
+// The error is a *DecodeError wrapping *UnknownFieldError.
 func (dec *Decoder) DisallowUnknownFields() {
 	dec.disallowUnknownFields = true
 }
 
+// ReportUnknownFields causes the Decoder to record the object keys
+// which do not match any non-ignored, exported fields in the destination.
+// Unlike DisallowUnknownFields, the keys are skipped without errors,
+// and they are available from UnknownFields after Decode.
+func (dec *Decoder) ReportUnknownFields() {
+	dec.reportUnknownFields = true
+}
+
+// UnknownFields returns the unknown fields that the last call to Decode skipped.
+// It is available with ReportUnknownFields.
+func (dec *Decoder) UnknownFields() []*UnknownFieldError {
+	return dec.unknownFields
+}
+
+// CollectErrors causes the Decoder to continue decoding after a value fails to decode.
+// The value is skipped, and the rest of the input is decoded as usual,
+// so the values decoded successfully are stored into the destination.
+// Decode returns a *DecodeErrors that has all the errors,
+// each of which has the JSON path and the position of the value.
+func (dec *Decoder) CollectErrors() {
+	dec.collectErrors = true
+}
+
+// AllowLeadingNumericStrings causes the Decoder to accept leading-numeric strings,
+// such as "123abc", when it converts strings into numbers.
+// The trailing garbage is ignored, so "123abc" is converted into 123 in the same way as PHP.
+// By default, only numeric strings are accepted.
+// See https://www.php.net/manual/en/language.types.numeric-strings.php for more detail.
+func (dec *Decoder) AllowLeadingNumericStrings() {
+	dec.allowLeadingNumeric = true
+}
+
+// ZeroOnNull causes the Decoder to set JSON null to bools, numbers, strings, arrays and structs as their zero values,
+// in the same way as PHP casts null, such as (int)null == 0, (string)null == "" and (array)null == [].
+// By default, null is ignored for them in the same way as encoding/json,
+// so the values decoded before survive.
+// Interfaces, pointers, maps and slices are always set to nil,
+// and types implementing Unmarshaler receive null as it is.
+func (dec *Decoder) ZeroOnNull() {
+	dec.zeroOnNull = true
+}
+
 // More reports whether there is another element in the current array or object being parsed.
 func (dec *Decoder) More() bool {
 	return dec.dec.More()
@@ -904,8 +1934,38 @@ func (dec *Decoder) Token() (json.Token, error) {
 }
 
 // UseNumber causes the Decoder to unmarshal a number into an interface{} as a Number instead of as a float64.
+// It is same as SetNumberMode(NumberJSONNumber).
 func (dec *Decoder) UseNumber() {
-	dec.useNumber = true
+	dec.numberMode = NumberJSONNumber
+}
+
+// NumberMode is the type of the Go values that a Decoder stores JSON numbers into interface{} as.
+type NumberMode int
+
+const (
+	// NumberFloat64 stores all numbers as float64, in the same way as encoding/json.
+	// It is the default.
+	NumberFloat64 NumberMode = iota
+
+	// NumberJSONNumber stores all numbers as Number.
+	NumberJSONNumber
+
+	// NumberPHP stores integers as int64 and other numbers as float64, in the same way as PHP's json_decode.
+	// For example, 1 is stored as int64(1), and 1.0 and 1e3 are stored as float64.
+	// The integers that overflow int64 are stored as float64,
+	// and the numbers out of the range of float64 are stored as ±Inf.
+	NumberPHP
+
+	// NumberPHPBigIntAsString is like NumberPHP, but the integers that overflow int64 are stored as string,
+	// in the same way as PHP's json_decode with JSON_BIGINT_AS_STRING.
+	NumberPHPBigIntAsString
+)
+
+// SetNumberMode sets the type of the Go values that the Decoder stores JSON numbers into interface{} as.
+// It applies to the numbers at every nesting level,
+// including the elements of []interface{}, map[string]interface{} and Array.
+func (dec *Decoder) SetNumberMode(m NumberMode) {
+	dec.numberMode = m
 }
 
 // Unmarshal parses the JSON-encoded data and stores the result
@@ -920,13 +1980,24 @@ func (dec *Decoder) UseNumber() {
 // The key of the object is interpreted as an index of the slice.
 // It is use for decoding PHP-encoded JSON with JSON_FORCE_OBJECT option.
 //
+// Types implementing Unmarshaler, including RawMessage, receive
+// the exact bytes of their value in data, without any reformatting.
+//
+// Types implementing sql.Scanner, such as sql.NullInt64, scan JSON literals with type juggling,
+// and null makes them invalid.
+//
 // And more, you can use “Type Juggling” of PHP.
 // For example, phperjson.Unmarshal can unmarshal a JSON string into int,
 // if the string can be parsed as number.
 // See http://php.net/manual/en/language.types.type-juggling.php for more detail.
 func Unmarshal(data []byte, v interface{}) error {
-	d := NewDecoder(bytes.NewReader(data))
-	return d.Decode(v)
+	if !json.Valid(data) {
+		// encoding/json reports the syntax error.
+		var raw RawMessage
+		return json.Unmarshal(data, &raw)
+	}
+	var dec Decoder
+	return dec.unmarshal(data, v)
 }
 
 // Valid is an alias for json.Valid.
@@ -934,6 +2005,67 @@ func Valid(data []byte) bool {
 	return json.Valid(data)
 }
 
+// A DecodeError describes an error while decoding a JSON value into a Go value.
+// It reports the full JSON path to the value, including struct fields, slice indexes and map keys,
+// and the position of the value in the input.
+// It wraps the underlying error, such as *UnmarshalTypeError
+// or the errors returned by the UnmarshalJSON and UnmarshalText methods of the destination.
+type DecodeError struct {
+	Path   string // the JSON path to the value, such as "items[3].price.amount"
+	Offset int64  // the input offset of the value
+	Line   int    // the line number of the value, starting at 1
+	Column int    // the column number of the value in bytes, starting at 1
+	Err    error  // the underlying error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return "phperjson: " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// An UnknownFieldError describes a JSON object key
+// which does not match any non-ignored, exported fields in the destination struct.
+type UnknownFieldError struct {
+	Key    string       // the object key
+	Type   reflect.Type // the type of the destination struct
+	Path   string       // the JSON path to the key, such as "items[3].unknown"
+	Offset int64        // the input offset of the key
+}
+
+func (e *UnknownFieldError) Error() string {
+	return "json: unknown field " + strconv.Quote(e.Key)
+}
+
+// DecodeErrors is the list of the errors that a Decoder collects with CollectErrors.
+// errors.Is and errors.As look into the errors collected on Go 1.13 and later.
+type DecodeErrors struct {
+	Errors []*DecodeError
+}
+
+func (e *DecodeErrors) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors collected.
+func (e *DecodeErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
 // UnmarshalFieldError is an alias for json.UnmarshalFieldError.
 type UnmarshalFieldError = json.UnmarshalFieldError
 
@@ -0,0 +1,32 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package phperjson
+
+import "errors"
+
+// Is reports whether any of the errors collected matches target.
+// errors.Is looks into Unwrap() []error only from Go 1.20, so it is implemented here for older versions.
+func (e *DecodeErrors) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors collected that matches target, and sets target to that error.
+// errors.As looks into Unwrap() []error only from Go 1.20, so it is implemented here for older versions.
+func (e *DecodeErrors) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
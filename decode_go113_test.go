@@ -0,0 +1,97 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package phperjson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeErrorAs(t *testing.T) {
+	var v struct {
+		Items []struct {
+			Price int `json:"price"`
+		} `json:"items"`
+	}
+	err := Unmarshal([]byte(`{"items":[{"price":"free"}]}`), &v)
+
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("want *DecodeError, got %#v", err)
+	}
+	if decErr.Path != "items[0].price" {
+		t.Errorf("unexpected path: %q", decErr.Path)
+	}
+
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("want *UnmarshalTypeError, got %#v", err)
+	}
+	if typeErr.Field != "price" || typeErr.Value != "string" {
+		t.Errorf("unexpected type error: %#v", typeErr)
+	}
+}
+
+func TestDecodeErrorAsUnmarshaler(t *testing.T) {
+	var v struct {
+		Items []struct {
+			At time.Time `json:"at"`
+		} `json:"items"`
+	}
+	err := Unmarshal([]byte(`{"items":[{"at":"yesterday"}]}`), &v)
+
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("want *DecodeError, got %#v", err)
+	}
+	if decErr.Path != "items[0].at" || decErr.Offset != 16 || decErr.Line != 1 || decErr.Column != 17 {
+		t.Errorf("unexpected position: %#v", decErr)
+	}
+
+	var parseErr *time.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("want *time.ParseError, got %#v", err)
+	}
+}
+
+func TestDecodeErrorsAs(t *testing.T) {
+	var v struct {
+		A int    `json:"a"`
+		B string `json:"b"`
+	}
+	dec := NewDecoder(strings.NewReader(`{"a":"x","b":[]}`))
+	dec.DisallowTypeJuggling()
+	dec.CollectErrors()
+	err := dec.Decode(&v)
+
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("want *DecodeError, got %#v", err)
+	}
+	if decErr.Path != "a" {
+		t.Errorf("unexpected path: %q", decErr.Path)
+	}
+
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("want *UnmarshalTypeError, got %#v", err)
+	}
+	if typeErr.Field != "a" {
+		t.Errorf("unexpected type error: %#v", typeErr)
+	}
+
+	errs := err.(*DecodeErrors)
+	if !errors.Is(err, errs.Errors[1]) {
+		t.Error("errors.Is doesn't find the second error")
+	}
+	if errors.Is(err, errMissingU8Prefix) {
+		t.Error("errors.Is finds an error not collected")
+	}
+}
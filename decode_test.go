@@ -8,9 +8,11 @@ package phperjson
 import (
 	"bytes"
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
+	"io"
 	"math"
 	"math/big"
 	"net"
@@ -21,6 +23,15 @@ import (
 	"time"
 )
 
+type Juggled struct {
+	ID     int    `juggle:"string-to-int"`
+	Name   string `juggle:"none"`
+	Flag   bool
+	Nested struct {
+		Tags []string `juggle:"string-to-array"`
+	} `juggle:"all"`
+}
+
 type T struct {
 	X string
 	Y int
@@ -382,8 +393,17 @@ type unmarshalTest struct {
 	ptr                   interface{}
 	out                   interface{}
 	err                   error
+	errPath               string // the path in *DecodeError
+	errOffset             int64  // the offset in *DecodeError
 	useNumber             bool
+	numberMode            NumberMode
 	disallowUnknownFields bool
+	allowLeadingNumeric   bool
+	disallowJuggling      bool
+	juggling              Juggling
+	phpVersion            PHPVersion
+	floatToInt            FloatToIntPolicy
+	objectToSlice         ObjectToSlicePolicy
 	golden                bool
 }
 
@@ -403,9 +423,9 @@ var unmarshalTests = []unmarshalTest{
 	{in: `"g-clef: \uD834\uDD1E"`, ptr: new(string), out: "g-clef: \U0001D11E"},
 	{in: `"invalid: \uD834x\uDD1E"`, ptr: new(string), out: "invalid: \uFFFDx\uFFFD"},
 	{in: "null", ptr: new(interface{}), out: nil},
-	{in: `{"X": [1,2,3], "Y": 4}`, ptr: new(T), out: T{Y: 4}, err: &UnmarshalTypeError{Value: "array", Type: reflect.TypeOf(""), Struct: "T", Field: "X"}},
+	{in: `{"X": [1,2,3], "Y": 4}`, ptr: new(T), out: T{Y: 4}, err: &UnmarshalTypeError{Value: "array", Type: reflect.TypeOf(""), Struct: "T", Field: "X"}, errPath: "X", errOffset: 6},
 	{in: `{"x": 1}`, ptr: new(tx), out: tx{}},
-	{in: `{"x": 1}`, ptr: new(tx), err: fmt.Errorf("json: unknown field \"x\""), disallowUnknownFields: true},
+	{in: `{"x": 1}`, ptr: new(tx), err: &UnknownFieldError{Key: "x", Type: reflect.TypeOf(tx{}), Path: "x", Offset: 1}, errPath: "x", errOffset: 1, disallowUnknownFields: true},
 	{in: `{"F1":1,"F2":2,"F3":3}`, ptr: new(V), out: V{F1: float64(1), F2: int32(2), F3: Number("3")}},
 	{in: `{"F1":1,"F2":2,"F3":3}`, ptr: new(V), out: V{F1: Number("1"), F2: int32(2), F3: Number("3")}, useNumber: true},
 	{in: `{"k1":1,"k2":"s","k3":[1,2.0,3e-3],"k4":{"kk1":"s","kk2":2}}`, ptr: new(interface{}), out: ifaceNumAsFloat64},
@@ -420,13 +440,13 @@ var unmarshalTests = []unmarshalTest{
 
 	// Z has a "-" tag.
 	{in: `{"Y": 1, "Z": 2}`, ptr: new(T), out: T{Y: 1}},
-	{in: `{"Y": 1, "Z": 2}`, ptr: new(T), err: fmt.Errorf("json: unknown field \"Z\""), disallowUnknownFields: true},
+	{in: `{"Y": 1, "Z": 2}`, ptr: new(T), err: &UnknownFieldError{Key: "Z", Type: reflect.TypeOf(T{}), Path: "Z", Offset: 9}, errPath: "Z", errOffset: 9, disallowUnknownFields: true},
 
 	{in: `{"alpha": "abc", "alphabet": "xyz"}`, ptr: new(U), out: U{Alphabet: "abc"}},
-	{in: `{"alpha": "abc", "alphabet": "xyz"}`, ptr: new(U), err: fmt.Errorf("json: unknown field \"alphabet\""), disallowUnknownFields: true},
+	{in: `{"alpha": "abc", "alphabet": "xyz"}`, ptr: new(U), err: &UnknownFieldError{Key: "alphabet", Type: reflect.TypeOf(U{}), Path: "alphabet", Offset: 17}, errPath: "alphabet", errOffset: 17, disallowUnknownFields: true},
 	{in: `{"alpha": "abc"}`, ptr: new(U), out: U{Alphabet: "abc"}},
 	{in: `{"alphabet": "xyz"}`, ptr: new(U), out: U{}},
-	{in: `{"alphabet": "xyz"}`, ptr: new(U), err: fmt.Errorf("json: unknown field \"alphabet\""), disallowUnknownFields: true},
+	{in: `{"alphabet": "xyz"}`, ptr: new(U), err: &UnknownFieldError{Key: "alphabet", Type: reflect.TypeOf(U{}), Path: "alphabet", Offset: 1}, errPath: "alphabet", errOffset: 1, disallowUnknownFields: true},
 
 	// syntax errors
 	// SyntaxError.msg is private field, so I can't test it.
@@ -515,31 +535,41 @@ var unmarshalTests = []unmarshalTest{
 		out: map[u8marshal]int{2: 4},
 	},
 	{
-		in:  `{"2":4}`,
-		ptr: new(map[u8marshal]int),
-		err: errMissingU8Prefix,
+		in:        `{"2":4}`,
+		ptr:       new(map[u8marshal]int),
+		err:       errMissingU8Prefix,
+		errPath:   `["2"]`,
+		errOffset: 1,
 	},
 
 	// integer-keyed map errors
 	{
-		in:  `{"abc":"abc"}`,
-		ptr: new(map[int]string),
-		err: &UnmarshalTypeError{Value: "number abc", Type: reflect.TypeOf(0)},
+		in:        `{"abc":"abc"}`,
+		ptr:       new(map[int]string),
+		err:       &UnmarshalTypeError{Value: "number abc", Type: reflect.TypeOf(0)},
+		errPath:   `abc`,
+		errOffset: 1,
 	},
 	{
-		in:  `{"256":"abc"}`,
-		ptr: new(map[uint8]string),
-		err: &UnmarshalTypeError{Value: "number 256", Type: reflect.TypeOf(uint8(0))},
+		in:        `{"256":"abc"}`,
+		ptr:       new(map[uint8]string),
+		err:       &UnmarshalTypeError{Value: "number 256", Type: reflect.TypeOf(uint8(0))},
+		errPath:   `["256"]`,
+		errOffset: 1,
 	},
 	{
-		in:  `{"128":"abc"}`,
-		ptr: new(map[int8]string),
-		err: &UnmarshalTypeError{Value: "number 128", Type: reflect.TypeOf(int8(0))},
+		in:        `{"128":"abc"}`,
+		ptr:       new(map[int8]string),
+		err:       &UnmarshalTypeError{Value: "number 128", Type: reflect.TypeOf(int8(0))},
+		errPath:   `["128"]`,
+		errOffset: 1,
 	},
 	{
-		in:  `{"-1":"abc"}`,
-		ptr: new(map[uint8]string),
-		err: &UnmarshalTypeError{Value: "number -1", Type: reflect.TypeOf(uint8(0))},
+		in:        `{"-1":"abc"}`,
+		ptr:       new(map[uint8]string),
+		err:       &UnmarshalTypeError{Value: "number -1", Type: reflect.TypeOf(uint8(0))},
+		errPath:   `["-1"]`,
+		errOffset: 1,
 	},
 
 	// Map keys can be encoding.TextUnmarshalers.
@@ -680,7 +710,9 @@ var unmarshalTests = []unmarshalTest{
 	{
 		in:                    `{"X": 1,"Y":2}`,
 		ptr:                   new(S5),
-		err:                   fmt.Errorf("json: unknown field \"X\""),
+		err:                   &UnknownFieldError{Key: "X", Type: reflect.TypeOf(S5{}), Path: "X", Offset: 1},
+		errPath:               "X",
+		errOffset:             1,
 		disallowUnknownFields: true,
 	},
 	{
@@ -691,7 +723,9 @@ var unmarshalTests = []unmarshalTest{
 	{
 		in:                    `{"X": 1,"Y":2}`,
 		ptr:                   new(S10),
-		err:                   fmt.Errorf("json: unknown field \"X\""),
+		err:                   &UnknownFieldError{Key: "X", Type: reflect.TypeOf(S10{}), Path: "X", Offset: 1},
+		errPath:               "X",
+		errOffset:             1,
 		disallowUnknownFields: true,
 	},
 
@@ -824,6 +858,8 @@ var unmarshalTests = []unmarshalTest{
 			Field:  "F2",
 			Type:   reflect.TypeOf(int32(0)),
 		},
+		errPath:   "V.F2",
+		errOffset: 13,
 	},
 	{
 		in:  `{"V": {"F4": {}, "F2": "hello"}}`,
@@ -834,6 +870,8 @@ var unmarshalTests = []unmarshalTest{
 			Field:  "F2",
 			Type:   reflect.TypeOf(int32(0)),
 		},
+		errPath:   "V.F2",
+		errOffset: 23,
 	},
 
 	// PHP flavored
@@ -870,6 +908,166 @@ var unmarshalTests = []unmarshalTest{
 	{in: `false`, ptr: new(int), out: 0},
 	{in: `""`, ptr: new(int), out: int(0)},
 
+	// numeric strings
+	{in: `" 42"`, ptr: new(int), out: 42},
+	{in: `"42 "`, ptr: new(int), out: 42},
+	{in: `"\t\n42\r\n"`, ptr: new(int), out: 42},
+	{in: `"+5"`, ptr: new(int), out: 5},
+	{in: `"-5"`, ptr: new(int), out: -5},
+	{in: `"5."`, ptr: new(int), out: 5},
+	{in: `".5"`, ptr: new(int), out: 0},
+	{in: `"1e3"`, ptr: new(int), out: 1000},
+	{in: `" 1.5E+1 "`, ptr: new(int64), out: int64(15)},
+	{in: `"+5"`, ptr: new(uint), out: uint(5)},
+	{in: `" 42 "`, ptr: new(uint8), out: uint8(42)},
+	{in: `".5"`, ptr: new(float64), out: 0.5},
+	{in: `"5."`, ptr: new(float64), out: 5.0},
+	{in: `" -.5e1 "`, ptr: new(float64), out: -5.0},
+	{in: `"4 2"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `"."`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `"+"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `" "`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `"0x1A"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `"1_000"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `"inf"`, ptr: new(float64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0.0)}},
+	{in: `"NaN"`, ptr: new(float64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0.0)}},
+	{in: `"0x1p4"`, ptr: new(float64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0.0)}},
+	{in: `"123abc"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}},
+	{in: `"123abc"`, ptr: new(int), out: 123, allowLeadingNumeric: true},
+	{in: `" 1.5e3xyz"`, ptr: new(float64), out: 1500.0, allowLeadingNumeric: true},
+	{in: `"7 apples"`, ptr: new(uint), out: uint(7), allowLeadingNumeric: true},
+	{in: `"1e"`, ptr: new(int), out: 1, allowLeadingNumeric: true},
+	{in: `"abc"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}, allowLeadingNumeric: true},
+
+	// PHP 7 numeric strings
+	{in: `" 42"`, ptr: new(int), out: 42, phpVersion: PHP7},
+	{in: `"42 "`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}, phpVersion: PHP7},
+	{in: `"42 "`, ptr: new(float64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0.0)}, phpVersion: PHP7},
+	{in: `"42 "`, ptr: new(int), out: 42, phpVersion: PHP7, allowLeadingNumeric: true},
+	{in: `"abc"`, ptr: new(int), out: 0, phpVersion: PHP7, allowLeadingNumeric: true},
+	{in: `"abc"`, ptr: new(float64), out: 0.0, phpVersion: PHP7, allowLeadingNumeric: true},
+	{in: `"42 "`, ptr: new(int), out: 42, phpVersion: PHP8},
+
+	// convert floating point numbers to integer
+	{in: `1.9`, ptr: new(int64), out: int64(1)},
+	{in: `-1.9`, ptr: new(int64), out: int64(-1)},
+	{in: `1.9`, ptr: new(uint64), out: uint64(1)},
+	{in: `"1.9"`, ptr: new(int64), out: int64(1)},
+	{in: `1e19`, ptr: new(int64), err: &UnmarshalTypeError{Value: "number 1e19", Type: reflect.TypeOf(int64(0))}},
+	{in: `1e19`, ptr: new(int64), out: int64(-8446744073709551616), phpVersion: PHP7},
+	{in: `"1e19"`, ptr: new(int64), out: int64(-8446744073709551616), phpVersion: PHP7},
+	{in: `9223372036854775808`, ptr: new(int64), out: int64(math.MinInt64), phpVersion: PHP7},
+	{in: `1e400`, ptr: new(int64), out: int64(0), phpVersion: PHP7},
+	{in: `-1e19`, ptr: new(uint64), out: uint64(8446744073709551616), phpVersion: PHP7},
+	{in: `-1`, ptr: new(uint64), out: uint64(math.MaxUint64), phpVersion: PHP7},
+	{in: `1e19`, ptr: new(int32), out: int32(-1981284352), phpVersion: PHP7},
+
+	// disallow type juggling
+	{in: `true`, ptr: new(string), err: &UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf("")}, disallowJuggling: true},
+	{in: `true`, ptr: new(int), err: &UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf(0)}, disallowJuggling: true},
+	{in: `false`, ptr: new(float64), err: &UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf(0.0)}, disallowJuggling: true},
+	{in: `true`, ptr: new([]bool), err: &UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf([]bool{})}, disallowJuggling: true},
+	{in: `1`, ptr: new(bool), err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf(true)}, disallowJuggling: true},
+	{in: `1`, ptr: new(string), err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf("")}, disallowJuggling: true},
+	{in: `1`, ptr: new([]int), err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf([]int{})}, disallowJuggling: true},
+	{in: `1`, ptr: new(map[string]int), err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf(map[string]int{})}, disallowJuggling: true},
+	{in: `"1"`, ptr: new(int), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}, disallowJuggling: true},
+	{in: `"1"`, ptr: new(uint), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(uint(0))}, disallowJuggling: true},
+	{in: `"1.5"`, ptr: new(float64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0.0)}, disallowJuggling: true},
+	{in: `"1"`, ptr: new(bool), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(true)}, disallowJuggling: true},
+	{in: `[1]`, ptr: new(bool), err: &UnmarshalTypeError{Value: "array", Type: reflect.TypeOf(true)}, disallowJuggling: true},
+	{in: `{}`, ptr: new(bool), err: &UnmarshalTypeError{Value: "object", Type: reflect.TypeOf(true)}, disallowJuggling: true},
+	{in: `{"Y":"1"}`, ptr: new(T), out: T{}, err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0), Struct: "T", Field: "Y"}, disallowJuggling: true, errPath: "Y", errOffset: 5},
+	{in: `{"1":"b","0":"a"}`, ptr: new([]string), out: []string{"a", "b"}, disallowJuggling: true},
+	{in: `["a","b"]`, ptr: new(map[int]string), out: map[int]string{0: "a", 1: "b"}, disallowJuggling: true},
+	{in: `"AQI="`, ptr: new([]byte), out: []byte{1, 2}, disallowJuggling: true},
+	{in: `1.5`, ptr: new(float64), out: 1.5, disallowJuggling: true},
+	{in: `null`, ptr: new(string), out: "", disallowJuggling: true},
+	{in: `"1"`, ptr: new(interface{}), out: "1", disallowJuggling: true},
+	{in: `1.5`, ptr: new(int), out: 1, disallowJuggling: true},
+	{in: `1e3`, ptr: new(int), out: 1000, disallowJuggling: true},
+	{in: `1.5`, ptr: new(int), err: &UnmarshalTypeError{Value: "number 1.5", Type: reflect.TypeOf(0)}, disallowJuggling: true, floatToInt: FloatToIntError},
+	{in: `1e3`, ptr: new(int), err: &UnmarshalTypeError{Value: "number 1e3", Type: reflect.TypeOf(0)}, disallowJuggling: true, floatToInt: FloatToIntError},
+
+	// juggling policies
+	{in: `"42"`, ptr: new(int), out: 42, juggling: JuggleStringToInt},
+	{in: `"42"`, ptr: new(float64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0.0)}, juggling: JuggleStringToInt},
+	{in: `true`, ptr: new(string), err: &UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf("")}, juggling: JuggleAll &^ JuggleBoolToString},
+	{in: `true`, ptr: new(int), out: 1, juggling: JuggleAll &^ JuggleBoolToString},
+	{in: `1`, ptr: new(string), out: "1", juggling: JuggleAll &^ JuggleBoolToString},
+	{in: `1`, ptr: new([]int), err: &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf([]int{})}, juggling: JuggleAll &^ JuggleNumberToArray},
+	{in: `"a"`, ptr: new([]string), out: []string{"a"}, juggling: JuggleAll &^ JuggleNumberToArray},
+	{in: `{"0":1}`, ptr: new([]int), err: &UnmarshalTypeError{Value: "object", Type: reflect.TypeOf([]int{})}, juggling: JuggleAll &^ JuggleObjectToArray},
+	{in: `{"0":1}`, ptr: new([1]int), err: &UnmarshalTypeError{Value: "object", Type: reflect.TypeOf([1]int{})}, juggling: JuggleAll &^ JuggleObjectToArray},
+	{in: `[1]`, ptr: new(map[string]int), err: &UnmarshalTypeError{Value: "array", Type: reflect.TypeOf(map[string]int{})}, juggling: JuggleAll &^ JuggleArrayToObject},
+	{in: `[1]`, ptr: new(bool), out: true, juggling: JuggleAll &^ JuggleObjectToBool},
+	{in: `{}`, ptr: new(bool), err: &UnmarshalTypeError{Value: "object", Type: reflect.TypeOf(true)}, juggling: JuggleAll &^ JuggleObjectToBool},
+	{
+		in:  `{"ID":"42","Flag":true,"Nested":{"Tags":"a"}}`,
+		ptr: new(Juggled),
+		out: Juggled{ID: 42, Flag: true, Nested: struct {
+			Tags []string `juggle:"string-to-array"`
+		}{Tags: []string{"a"}}},
+		disallowJuggling: true,
+	},
+	{
+		in:               `{"Flag":"1"}`,
+		ptr:              new(Juggled),
+		err:              &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(true), Struct: "Juggled", Field: "Flag"},
+		errPath:          `Flag`,
+		errOffset:        8,
+		disallowJuggling: true,
+	},
+	{
+		in:        `{"ID":"42","Name":1}`,
+		ptr:       new(Juggled),
+		out:       Juggled{ID: 42},
+		err:       &UnmarshalTypeError{Value: "number", Type: reflect.TypeOf(""), Struct: "Juggled", Field: "Name"},
+		errPath:   `Name`,
+		errOffset: 18,
+	},
+
+	// float to integer policies
+	{in: `1.9`, ptr: new(int64), out: int64(1), floatToInt: FloatToIntTruncate},
+	{in: `1e19`, ptr: new(int64), err: &UnmarshalTypeError{Value: "number 1e19", Type: reflect.TypeOf(int64(0))}, floatToInt: FloatToIntTruncate, phpVersion: PHP7},
+	{in: `1.9`, ptr: new(int64), err: &UnmarshalTypeError{Value: "number 1.9", Type: reflect.TypeOf(int64(0))}, floatToInt: FloatToIntRejectFraction},
+	{in: `"1.9"`, ptr: new(uint), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(uint(0))}, floatToInt: FloatToIntRejectFraction},
+	{in: `1.0`, ptr: new(int64), out: int64(1), floatToInt: FloatToIntRejectFraction},
+	{in: `1e3`, ptr: new(uint8), err: &UnmarshalTypeError{Value: "number 1e3", Type: reflect.TypeOf(uint8(0))}, floatToInt: FloatToIntRejectFraction},
+	{in: `1e19`, ptr: new(int64), out: int64(math.MaxInt64), floatToInt: FloatToIntSaturate},
+	{in: `-1e19`, ptr: new(int64), out: int64(math.MinInt64), floatToInt: FloatToIntSaturate},
+	{in: `300.5`, ptr: new(int8), out: int8(math.MaxInt8), floatToInt: FloatToIntSaturate},
+	{in: `-300`, ptr: new(int16), out: int16(-300), floatToInt: FloatToIntSaturate},
+	{in: `-300`, ptr: new(int8), out: int8(math.MinInt8), floatToInt: FloatToIntSaturate},
+	{in: `-1.5`, ptr: new(uint32), out: uint32(0), floatToInt: FloatToIntSaturate},
+	{in: `1e20`, ptr: new(uint64), out: uint64(math.MaxUint64), floatToInt: FloatToIntSaturate},
+	{in: `70000`, ptr: new(uint16), out: uint16(math.MaxUint16), floatToInt: FloatToIntSaturate},
+	{in: `300`, ptr: new(uint8), out: uint8(44), floatToInt: FloatToIntWrap},
+	{in: `3000000000`, ptr: new(int32), out: int32(-1294967296), floatToInt: FloatToIntWrap},
+	{in: `-1`, ptr: new(uint16), out: uint16(math.MaxUint16), floatToInt: FloatToIntWrap},
+	{in: `1e19`, ptr: new(int64), out: int64(-8446744073709551616), floatToInt: FloatToIntWrap, phpVersion: PHP8},
+	{in: `1e19`, ptr: new(int64), err: &UnmarshalTypeError{Value: "number 1e19", Type: reflect.TypeOf(int64(0))}, floatToInt: FloatToIntError, phpVersion: PHP7},
+	{in: `1.0`, ptr: new(int64), err: &UnmarshalTypeError{Value: "number 1.0", Type: reflect.TypeOf(int64(0))}, floatToInt: FloatToIntError},
+	{in: `"1e3"`, ptr: new(int64), err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(int64(0))}, floatToInt: FloatToIntError},
+	{in: `-5`, ptr: new(int64), out: int64(-5), floatToInt: FloatToIntError},
+	{in: `1.5`, ptr: new(float64), out: 1.5, floatToInt: FloatToIntError},
+
+	// object to slice policies
+	{in: `{"0":"a","3":"b"}`, ptr: new([]string), out: []string{"a", "", "", "b"}},
+	{in: `{"3":"b","0":"a"}`, ptr: new([]string), out: []string{"a", "", "", "b"}},
+	{in: `{"-1":"x","1":"y"}`, ptr: new([2]string), err: &UnmarshalTypeError{Value: `object key "-1"`, Type: reflect.TypeOf([2]string{})}, errPath: `["-1"]`, errOffset: 1},
+	{in: `{"-1":"x","1":"y"}`, ptr: new([]string), err: &UnmarshalTypeError{Value: `object key "-1"`, Type: reflect.TypeOf([]string{})}, errPath: `["-1"]`, errOffset: 1},
+	{in: `{"1":"y","5":"z"}`, ptr: new([2]string), out: [2]string{"", "y"}},
+	{in: `{"08":"x"}`, ptr: new([]string), err: &UnmarshalTypeError{Value: `object key "08"`, Type: reflect.TypeOf([]string{})}, errPath: `["08"]`, errOffset: 1},
+	{in: `{"a":1}`, ptr: new([1]int), err: &UnmarshalTypeError{Value: `object key "a"`, Type: reflect.TypeOf([1]int{})}, errPath: "a", errOffset: 1},
+	{in: `{"0":"a","1":"b"}`, ptr: new([]string), out: []string{"a", "b"}, objectToSlice: ObjectToSliceError},
+	{in: `{"0":"a","3":"b"}`, ptr: new([]string), err: &UnmarshalTypeError{Value: `object key "3"`, Type: reflect.TypeOf([]string{})}, errPath: `["3"]`, errOffset: 9, objectToSlice: ObjectToSliceError},
+	{in: `{"1":"b","0":"a"}`, ptr: new([2]string), err: &UnmarshalTypeError{Value: `object key "1"`, Type: reflect.TypeOf([2]string{})}, errPath: `["1"]`, errOffset: 1, objectToSlice: ObjectToSliceError},
+	{in: `{"0":"a","3":"b"}`, ptr: new([]string), out: []string{"a", "b"}, objectToSlice: ObjectToSliceCompact},
+	{in: `{"-1":"x","a":"y","08":"z"}`, ptr: new([]string), out: []string{"x", "y", "z"}, objectToSlice: ObjectToSliceCompact},
+	{in: `{"a":1,"b":2,"c":3}`, ptr: new([2]int), out: [2]int{1, 2}, objectToSlice: ObjectToSliceCompact},
+	{in: `{"a":1,"b":"x"}`, ptr: new([]int), out: []int{1, 0}, err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)}, errPath: "b", errOffset: 11, objectToSlice: ObjectToSliceCompact},
+
 	// convert to unsigned integer
 	{in: `"1"`, ptr: new(uint), out: uint(1)},
 	{in: `"1.1"`, ptr: new(uint), out: uint(1)},
@@ -893,6 +1091,8 @@ var unmarshalTests = []unmarshalTest{
 	{in: `"foo"`, ptr: new([]string), out: []string{"foo"}},
 	{in: `{}`, ptr: new([]interface{}), out: []interface{}{}},
 	{in: `{"1":1}`, ptr: new([]int), out: []int{0, 1}},
+	{in: `{"1":"a","0":"b"}`, ptr: new([]string), out: []string{"b", "a"}},
+	{in: `{"1":{"0":1},"0":[2]}`, ptr: new([][]int), out: [][]int{{2}, {1}}},
 	{in: `{"1":1,"3":3}`, ptr: new([3]int), out: [3]int{0, 1, 0}},
 	{in: `true`, ptr: new(map[string]bool), out: map[string]bool{"0": true}},
 	{in: `1`, ptr: new(map[string]int), out: map[string]int{"0": 1}},
@@ -918,6 +1118,15 @@ var unmarshalTests = []unmarshalTest{
 	{in: `"foo"`, ptr: new(interface{}), out: "foo"},
 	{in: `{}`, ptr: new(interface{}), out: map[string]interface{}{}},
 	{in: `[]`, ptr: new(interface{}), out: []interface{}{}},
+	{in: `[1,2.5]`, ptr: new(interface{}), out: []interface{}{1.0, 2.5}},
+	{in: `[1,2.5]`, ptr: new(interface{}), out: []interface{}{Number("1"), Number("2.5")}, useNumber: true},
+	{in: `[1,2.5]`, ptr: new(interface{}), out: []interface{}{Number("1"), Number("2.5")}, numberMode: NumberJSONNumber},
+	{in: `[1,1.0,1e3,-0,9223372036854775807,-9223372036854775808]`, ptr: new(interface{}), out: []interface{}{int64(1), 1.0, 1e3, int64(0), int64(math.MaxInt64), int64(math.MinInt64)}, numberMode: NumberPHP},
+	{in: `{"a":[{"b":1,"c":1.5}],"d":9223372036854775808}`, ptr: new(interface{}), out: map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": int64(1), "c": 1.5}}, "d": 9223372036854775808.0}, numberMode: NumberPHP},
+	{in: `[9223372036854775808,-9223372036854775809,2.5]`, ptr: new([]interface{}), out: []interface{}{"9223372036854775808", "-9223372036854775809", 2.5}, numberMode: NumberPHPBigIntAsString},
+	{in: `{"a":1,"b":2.5}`, ptr: new(map[string]interface{}), out: map[string]interface{}{"a": int64(1), "b": 2.5}, numberMode: NumberPHP},
+	{in: `[1,"2"]`, ptr: new([2]interface{}), out: [2]interface{}{int64(1), "2"}, numberMode: NumberPHP},
+	{in: `{"A":1}`, ptr: new(struct{ A interface{} }), out: struct{ A interface{} }{A: int64(1)}, numberMode: NumberPHP},
 
 	{
 		in:  `true`,
@@ -1082,12 +1291,53 @@ func TestUnmarshal(t *testing.T) {
 		if tt.useNumber {
 			dec.UseNumber()
 		}
+		if tt.numberMode != NumberFloat64 {
+			dec.SetNumberMode(tt.numberMode)
+		}
 		if tt.disallowUnknownFields {
 			dec.DisallowUnknownFields()
 		}
+		if tt.allowLeadingNumeric {
+			dec.AllowLeadingNumericStrings()
+		}
+		if tt.disallowJuggling {
+			dec.DisallowTypeJuggling()
+		}
+		if tt.juggling != JuggleNone {
+			dec.SetJuggling(tt.juggling)
+		}
+		if tt.phpVersion != 0 {
+			dec.SetPHPVersion(tt.phpVersion)
+		}
+		if tt.floatToInt != FloatToIntDefault {
+			dec.SetFloatToIntPolicy(tt.floatToInt)
+		}
+		if tt.objectToSlice != ObjectToSliceSparse {
+			dec.SetObjectToSlicePolicy(tt.objectToSlice)
+		}
+		wantErr := tt.err
+		if err, ok := tt.err.(*UnmarshalTypeError); ok {
+			typeErr := *err
+			typeErr.Offset = tt.errOffset
+			wantErr = &DecodeError{
+				Path:   tt.errPath,
+				Offset: tt.errOffset,
+				Line:   1,
+				Column: int(tt.errOffset) + 1,
+				Err:    &typeErr,
+			}
+		} else if tt.errPath != "" {
+			wantErr = &DecodeError{
+				Path:   tt.errPath,
+				Offset: tt.errOffset,
+				Line:   1,
+				Column: int(tt.errOffset) + 1,
+				Err:    tt.err,
+			}
+		}
 		v := reflect.New(reflect.TypeOf(tt.ptr).Elem())
-		if err := dec.Decode(v.Interface()); !reflect.DeepEqual(err, tt.err) {
-			t.Errorf("#%d: %v, want %v", i, err, tt.err)
+		if err := dec.Decode(v.Interface()); !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("#%d: %v, want %v", i, err, wantErr)
 			continue
 		} else if err != nil {
 			continue
@@ -1103,7 +1353,12 @@ func TestUnmarshal(t *testing.T) {
 
 		// Check round trip also decodes correctly.
 		if tt.err == nil {
-			enc, err := Marshal(v.Interface())
+			var flags EncodeFlag
+			if tt.numberMode == NumberPHP || tt.numberMode == NumberPHPBigIntAsString {
+				// 1.0 is encoded as 1 by default, and decoded into int64.
+				flags = EncodePreserveZeroFraction
+			}
+			enc, err := MarshalFlags(v.Interface(), flags)
 			if err != nil {
 				t.Errorf("#%d: error re-marshaling: %v", i, err)
 			}
@@ -1115,11 +1370,15 @@ func TestUnmarshal(t *testing.T) {
 			if tt.useNumber {
 				dec.UseNumber()
 			}
+			if tt.numberMode != NumberFloat64 {
+				dec.SetNumberMode(tt.numberMode)
+			}
 			if err := dec.Decode(vv.Interface()); err != nil {
 				t.Errorf("#%d: error re-unmarshaling %#q: %v", i, enc, err)
 				continue
 			}
-			if !reflect.DeepEqual(v.Elem().Interface(), vv.Elem().Interface()) {
+			// Marshal encodes nil slices as [], so they come back as empty slices.
+			if !equalNilSliceAsEmpty(v.Elem(), vv.Elem()) {
 				t.Errorf("#%d: mismatch\nhave: %#+v\nwant: %#+v", i, v.Elem().Interface(), vv.Elem().Interface())
 				t.Errorf("     In: %q", strings.Map(noSpace, string(in)))
 				t.Errorf("Marshal: %q", strings.Map(noSpace, string(enc)))
@@ -1129,6 +1388,70 @@ func TestUnmarshal(t *testing.T) {
 	}
 }
 
+// equalNilSliceAsEmpty is same as reflect.DeepEqual,
+// except that it treats nil slices as equal to empty slices.
+func equalNilSliceAsEmpty(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalNilSliceAsEmpty(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !equalNilSliceAsEmpty(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalNilSliceAsEmpty(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equalNilSliceAsEmpty(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		for _, k := range a.MapKeys() {
+			bv := b.MapIndex(k)
+			if !bv.IsValid() || !equalNilSliceAsEmpty(a.MapIndex(k), bv) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.String:
+		return a.String() == b.String()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
 func isSpace(c byte) bool {
 	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
 }
@@ -1826,6 +2149,639 @@ func TestInvalidUnmarshalText(t *testing.T) {
 	}
 }
 
+func TestDecodeStream(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`[{"X":"1","Y":"2"}, {"0":"a","1":3}] true`))
+	tok, err := dec.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != Delim('[') {
+		t.Fatalf("want [, got %v", tok)
+	}
+	var v1 T
+	if err := dec.Decode(&v1); err != nil {
+		t.Fatal(err)
+	}
+	if want := (T{X: "1", Y: 2}); v1 != want {
+		t.Errorf("want %#v, got %#v", want, v1)
+	}
+	var v2 []string
+	if err := dec.Decode(&v2); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "3"}; !reflect.DeepEqual(v2, want) {
+		t.Errorf("want %#v, got %#v", want, v2)
+	}
+	if dec.More() {
+		t.Error("want no more elements")
+	}
+	if _, err := dec.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var v3 int
+	if err := dec.Decode(&v3); err != nil {
+		t.Fatal(err)
+	}
+	if v3 != 1 {
+		t.Errorf("want 1, got %d", v3)
+	}
+}
+
+func TestUnmarshalSyntaxError(t *testing.T) {
+	tests := []string{
+		``,
+		`{"X": "foo", "Y"}`,
+		`[1, 2, 3+]`,
+		`1 2`,
+	}
+	for _, in := range tests {
+		var v interface{}
+		err := Unmarshal([]byte(in), &v)
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("Unmarshal(%#q): want SyntaxError, got %v", in, err)
+		}
+	}
+}
+
+func TestUnmarshalWideStruct(t *testing.T) {
+	typ, data := wideStruct(128)
+	want := reflect.New(typ)
+	if err := json.Unmarshal(data, want.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	got := reflect.New(typ)
+	if err := Unmarshal(data, got.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Interface(), want.Interface()) {
+		t.Errorf("have %#v, want %#v", got.Interface(), want.Interface())
+	}
+
+	// the keys are case-insensitive.
+	got = reflect.New(typ)
+	if err := Unmarshal(bytes.Replace(data, []byte(`"field_`), []byte(`"FIELD_`), -1), got.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Elem().FieldByName("Field127").Interface(); v != true {
+		t.Errorf("Field127: have %v, want true", v)
+	}
+}
+
+// rawRecorder records the bytes passed to UnmarshalJSON.
+type rawRecorder []byte
+
+func (r *rawRecorder) UnmarshalJSON(data []byte) error {
+	*r = append((*r)[0:0], data...)
+	return nil
+}
+
+// Unmarshalers and RawMessages must receive the exact bytes of the input.
+func TestUnmarshalRawBytes(t *testing.T) {
+	type signed struct {
+		Payload   RawMessage
+		Signature string
+	}
+	type recorded struct {
+		Payload rawRecorder
+	}
+	type indexed struct {
+		First rawRecorder `json:"0"`
+	}
+	const payload = `{ "z":1.0E+25 , "a" : [ 1 , "\u00e9", -0.0 ], "b":null }`
+	tests := []struct {
+		in   string
+		ptr  interface{}
+		want func(v interface{}) []byte
+	}{
+		{
+			in:   `{"Payload": ` + payload + `, "Signature": "xxx"}`,
+			ptr:  new(signed),
+			want: func(v interface{}) []byte { return v.(*signed).Payload },
+		},
+		{
+			in:   `{"Payload": ` + payload + `}`,
+			ptr:  new(recorded),
+			want: func(v interface{}) []byte { return v.(*recorded).Payload },
+		},
+		{
+			in:   `[` + payload + `]`,
+			ptr:  new(indexed),
+			want: func(v interface{}) []byte { return v.(*indexed).First },
+		},
+		{
+			in:   `{"0": ` + payload + `}`,
+			ptr:  new([]RawMessage),
+			want: func(v interface{}) []byte { return (*v.(*[]RawMessage))[0] },
+		},
+		{
+			in:   `{"key": ` + payload + `}`,
+			ptr:  new(map[string]RawMessage),
+			want: func(v interface{}) []byte { return (*v.(*map[string]RawMessage))["key"] },
+		},
+		{
+			in:   ` ` + payload + ` `,
+			ptr:  new(RawMessage),
+			want: func(v interface{}) []byte { return *v.(*RawMessage) },
+		},
+		{
+			in:   `1.0E+25`,
+			ptr:  new([]RawMessage),
+			want: func(v interface{}) []byte { return (*v.(*[]RawMessage))[0] },
+		},
+	}
+	for i, tt := range tests {
+		want := strings.TrimSpace(tt.in)
+		if strings.Contains(tt.in, payload) {
+			want = payload
+		}
+
+		v := reflect.New(reflect.TypeOf(tt.ptr).Elem()).Interface()
+		if err := Unmarshal([]byte(tt.in), v); err != nil {
+			t.Errorf("#%d: Unmarshal: %v", i, err)
+			continue
+		}
+		if got := string(tt.want(v)); got != want {
+			t.Errorf("#%d: Unmarshal: have %#q, want %#q", i, got, want)
+		}
+
+		v = reflect.New(reflect.TypeOf(tt.ptr).Elem()).Interface()
+		if err := NewDecoder(strings.NewReader(tt.in)).Decode(v); err != nil {
+			t.Errorf("#%d: Decode: %v", i, err)
+			continue
+		}
+		if got := string(tt.want(v)); got != want {
+			t.Errorf("#%d: Decode: have %#q, want %#q", i, got, want)
+		}
+	}
+}
+
+func TestInvalidJuggleTag(t *testing.T) {
+	type typo struct {
+		ID int `juggle:"string-to-integer"`
+	}
+	for _, in := range []string{`{"ID":"5"}`, `["5"]`, `"5"`} {
+		var v typo
+		err := Unmarshal([]byte(in), &v)
+		want := `phperjson: invalid juggle tag of phperjson.typo.ID: unknown conversion "string-to-integer"`
+		if err == nil || !strings.HasSuffix(err.Error(), want) {
+			t.Errorf("Unmarshal(%s): got error %v, want %s", in, err, want)
+		}
+	}
+}
+
+func TestCoercionHook(t *testing.T) {
+	type Price struct {
+		Amount int
+	}
+	type Item struct {
+		Name  string
+		Price Price
+		Tags  []string
+	}
+	var v struct {
+		Items   []Item `json:"items"`
+		Enabled bool   `json:"enabled"`
+		Labels  map[string]string
+	}
+	in := `{"items":{"1":{"Name":1,"Price":{"Amount":"42"},"Tags":"a"}},"enabled":[],"Labels":{"0":true,"a b":"c"}}`
+	var got []Coercion
+	dec := NewDecoder(strings.NewReader(in))
+	dec.SetCoercionHook(func(c Coercion) {
+		c.Value = append(RawMessage(nil), c.Value...)
+		got = append(got, c)
+	})
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := []Coercion{
+		{Path: "items[1].Name", Conversion: JuggleNumberToString, Source: "number", Type: reflect.TypeOf(""), Value: RawMessage(`1`)},
+		{Path: "items[1].Price.Amount", Conversion: JuggleStringToInt, Source: "string", Type: reflect.TypeOf(0), Value: RawMessage(`"42"`)},
+		{Path: "items[1].Tags", Conversion: JuggleStringToArray, Source: "string", Type: reflect.TypeOf([]string{}), Value: RawMessage(`"a"`)},
+		{Path: "items", Conversion: JuggleObjectToArray, Source: "object", Type: reflect.TypeOf([]Item{}), Value: RawMessage(`{"1":{"Name":1,"Price":{"Amount":"42"},"Tags":"a"}}`)},
+		{Path: "enabled", Conversion: JuggleArrayToBool, Source: "array", Type: reflect.TypeOf(true), Value: RawMessage(`[]`)},
+		{Path: `Labels["0"]`, Conversion: JuggleBoolToString, Source: "bool", Type: reflect.TypeOf(""), Value: RawMessage(`true`)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	// failed conversions are not reported.
+	got = nil
+	var n struct {
+		A int
+		B []int
+	}
+	dec = NewDecoder(strings.NewReader(`{"A":"x","B":"y"}`))
+	dec.SetCoercionHook(func(c Coercion) {
+		got = append(got, c)
+	})
+	dec.CollectErrors()
+	if err := dec.Decode(&n); err == nil {
+		t.Error("want error, got nil")
+	}
+	if len(got) != 0 {
+		t.Errorf("failed conversions are reported: %#v", got)
+	}
+}
+
+type pathPrice struct {
+	Amount int `json:"amount"`
+}
+
+type pathItem struct {
+	Price pathPrice `json:"price"`
+}
+
+type pathTuple struct {
+	A int `json:"0"`
+	B int `json:"1"`
+}
+
+type pathOrder struct {
+	Items []pathItem     `json:"items"`
+	Meta  map[int]string `json:"meta"`
+	Tuple pathTuple      `json:"tuple"`
+}
+
+var decodeErrorPathTests = []struct {
+	in               string
+	ptr              interface{}
+	disallowJuggling bool
+	path             string
+	offset           int64
+	line, column     int
+	err              *UnmarshalTypeError
+}{
+	{
+		in: `{
+  "items": [
+    {}, {}, {},
+    {"price": {"amount": "x"}}
+  ]
+}`,
+		ptr:    new(pathOrder),
+		path:   "items[3].price.amount",
+		offset: 56, line: 4, column: 26,
+		err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0), Struct: "pathPrice", Field: "amount"},
+	},
+	{
+		in:     `{"items":{"5":{"price":{"amount":"x"}}}}`,
+		ptr:    new(pathOrder),
+		path:   "items[5].price.amount",
+		offset: 33, line: 1, column: 34,
+		err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0), Struct: "pathPrice", Field: "amount"},
+	},
+	{
+		in:               `{"items":[{"price":{"amount":1}},true]}`,
+		ptr:              new(pathOrder),
+		disallowJuggling: true,
+		path:             "items[1]",
+		offset:           33, line: 1, column: 34,
+		err: &UnmarshalTypeError{Value: "bool", Type: reflect.TypeOf(pathItem{}), Struct: "pathOrder", Field: "items"},
+	},
+	{
+		in:     `{"meta":{"x":"y"}}`,
+		ptr:    new(pathOrder),
+		path:   "meta.x",
+		offset: 9, line: 1, column: 10,
+		err: &UnmarshalTypeError{Value: "number x", Type: reflect.TypeOf(0), Struct: "pathOrder", Field: "meta"},
+	},
+	{
+		in:               `{"tuple":[1,"2"]}`,
+		ptr:              new(pathOrder),
+		disallowJuggling: true,
+		path:             "tuple[1]",
+		offset:           12, line: 1, column: 13,
+		err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0), Struct: "pathTuple", Field: "1"},
+	},
+	{
+		in:     `{"a b":{"c":[1,"x"]}}`,
+		ptr:    new(map[string]map[string][]int),
+		path:   `["a b"].c[1]`,
+		offset: 15, line: 1, column: 16,
+		err: &UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0)},
+	},
+	{
+		in:     `{"x":1}`,
+		ptr:    new([]int),
+		path:   "x",
+		offset: 1, line: 1, column: 2,
+		err: &UnmarshalTypeError{Value: `object key "x"`, Type: reflect.TypeOf([]int{})},
+	},
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	for i, tt := range decodeErrorPathTests {
+		dec := NewDecoder(strings.NewReader(tt.in))
+		if tt.disallowJuggling {
+			dec.DisallowTypeJuggling()
+		}
+		err := dec.Decode(tt.ptr)
+		typeErr := *tt.err
+		typeErr.Offset = tt.offset
+		want := &DecodeError{Path: tt.path, Offset: tt.offset, Line: tt.line, Column: tt.column, Err: &typeErr}
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("#%d: got %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	type Record struct {
+		ID     int            `json:"id"`
+		Name   string         `json:"name"`
+		Price  float64        `json:"price"`
+		Tags   []int          `json:"tags"`
+		Counts map[int]int    `json:"counts"`
+		Extra  map[string]int `json:"extra"`
+		Index  map[int]int    `json:"index"`
+	}
+	in := `{"id":"x","name":"foo","price":[1],"tags":[1,"a",3],"counts":{"1":1,"x":2},"unknown":1,"extra":{"a":1,"b":"x"},"index":[1,"y"]}`
+	dec := NewDecoder(strings.NewReader(in))
+	dec.DisallowUnknownFields()
+	dec.DisallowTypeJuggling()
+	dec.CollectErrors()
+	var got Record
+	err := dec.Decode(&got)
+
+	want := Record{
+		Name:   "foo",
+		Tags:   []int{1, 0, 3},
+		Counts: map[int]int{1: 1},
+		Extra:  map[string]int{"a": 1},
+		Index:  map[int]int{0: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	errs, ok := err.(*DecodeErrors)
+	if !ok {
+		t.Fatalf("want *DecodeErrors, got %#v", err)
+	}
+	type pos struct {
+		path   string
+		offset int64
+	}
+	var gotPos []pos
+	for _, err := range errs.Errors {
+		gotPos = append(gotPos, pos{err.Path, err.Offset})
+	}
+	wantPos := []pos{
+		{"id", 6},
+		{"price", 31},
+		{"tags[1]", 45},
+		{"counts.x", 68},
+		{"unknown", 75},
+		{"extra.b", 106},
+		{"index[1]", 122},
+	}
+	if !reflect.DeepEqual(gotPos, wantPos) {
+		t.Errorf("got %v, want %v", gotPos, wantPos)
+	}
+	if len(errs.Unwrap()) != len(wantPos) {
+		t.Errorf("want %d errors, got %d", len(wantPos), len(errs.Unwrap()))
+	}
+
+	// without errors, Decode returns nil.
+	dec = NewDecoder(strings.NewReader(`{"id":1}`))
+	dec.CollectErrors()
+	if err := dec.Decode(&got); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestReportUnknownFields(t *testing.T) {
+	in := `{"items":[{"price":{"amount":1,"currency":"JPY"}}],"total":1}
+{"meta":{}}`
+	dec := NewDecoder(strings.NewReader(in))
+	dec.ReportUnknownFields()
+
+	var v pathOrder
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := []*UnknownFieldError{
+		{Key: "currency", Type: reflect.TypeOf(pathPrice{}), Path: "items[0].price.currency", Offset: 31},
+		{Key: "total", Type: reflect.TypeOf(pathOrder{}), Path: "total", Offset: 51},
+	}
+	if got := dec.UnknownFields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if v.Items[0].Price.Amount != 1 {
+		t.Errorf("unexpected amount: %d", v.Items[0].Price.Amount)
+	}
+
+	// the next Decode clears the unknown fields.
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if got := dec.UnknownFields(); len(got) != 0 {
+		t.Errorf("want no unknown fields, got %#v", got)
+	}
+}
+
+var limitTests = []struct {
+	in     string
+	ptr    interface{}
+	limits Limits
+	err    error // nil if no error
+	offset int64
+	path   string
+}{
+	{in: `[[[1]]]`, ptr: new(interface{}), limits: Limits{MaxDepth: 3}},
+	{in: `[[[1]]]`, ptr: new(interface{}), limits: Limits{MaxDepth: 2}, err: &LimitError{Limit: "depth", Max: 2}, offset: 2},
+	{in: `{"a":{"b":"[[[[}"}}`, ptr: new(interface{}), limits: Limits{MaxDepth: 2}},
+	{in: `[1,2,{"a":3}]`, ptr: new(interface{}), limits: Limits{MaxElements: 4}},
+	{in: `[1,2,{"a":3,"b":4}]`, ptr: new(interface{}), limits: Limits{MaxElements: 4}, err: &LimitError{Limit: "elements", Max: 4}, offset: 11},
+	{in: `[[], {}, "a,b"]`, ptr: new(interface{}), limits: Limits{MaxElements: 3}},
+	{in: `[1, 2, 3]`, ptr: new([]int), limits: Limits{MaxBytes: 9}},
+	{in: `[1, 2, 3]`, ptr: new([]int), limits: Limits{MaxBytes: 8}, err: &LimitError{Limit: "bytes", Max: 8}},
+	{in: `{"99":1}`, ptr: new([]int), limits: Limits{MaxSliceIndex: 99}},
+	{in: `{"100":1}`, ptr: new([]int), limits: Limits{MaxSliceIndex: 99}, err: &LimitError{Limit: "slice index", Max: 99}, offset: 1, path: `["100"]`},
+	{in: `{"-1":1}`, ptr: new([]int), err: &UnmarshalTypeError{Value: `object key "-1"`, Type: reflect.TypeOf([]int{}), Offset: 1}, offset: 1, path: `["-1"]`},
+	{in: `{"-1":1,"0":2}`, ptr: new([1]int), err: &UnmarshalTypeError{Value: `object key "-1"`, Type: reflect.TypeOf([1]int{}), Offset: 1}, offset: 1, path: `["-1"]`},
+	{in: `{"0":1,"1":2}`, ptr: new([1]int)},
+	{in: `{"65535":1}`, ptr: new([]int64)},
+	{in: `{"65536":1}`, ptr: new([]int64), err: &LimitError{Limit: "slice index", Max: 65535}, offset: 1, path: `["65536"]`},
+	{in: `{"999999999":1}`, ptr: new([]int64), err: &LimitError{Limit: "slice index", Max: 65535}, offset: 1, path: `["999999999"]`},
+	{in: `{"999999999":1}`, ptr: new([1]int64)},
+	{in: `{"9223372036854775806":1}`, ptr: new([]int), err: &LimitError{Limit: "slice index", Max: 65535}, offset: 1, path: `["9223372036854775806"]`},
+	{in: `{"9223372036854775806":1}`, ptr: new([]int), limits: Limits{MaxSliceIndex: -1}, err: &UnmarshalTypeError{Value: `object key "9223372036854775806"`, Type: reflect.TypeOf([]int{}), Offset: 1}, offset: 1, path: `["9223372036854775806"]`},
+	{in: `{"9223372036854775806":1}`, ptr: new([]struct{}), limits: Limits{MaxSliceIndex: -1}, err: &UnmarshalTypeError{Value: `object key "9223372036854775806"`, Type: reflect.TypeOf([]struct{}{}), Offset: 1}, offset: 1, path: `["9223372036854775806"]`},
+}
+
+func TestLimits(t *testing.T) {
+	for i, tt := range limitTests {
+		dec := NewDecoder(strings.NewReader(tt.in))
+		dec.SetLimits(tt.limits)
+		err := dec.Decode(reflect.New(reflect.TypeOf(tt.ptr).Elem()).Interface())
+		var want error
+		if tt.err != nil {
+			want = &DecodeError{Path: tt.path, Offset: tt.offset, Line: 1, Column: int(tt.offset) + 1, Err: tt.err}
+		}
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("#%d: got %v, want %v", i, err, want)
+		}
+	}
+}
+
+// endlessArray is an io.Reader of the endless JSON array [1,1,1,...
+type endlessArray struct {
+	n int64 // the number of bytes read
+}
+
+func (r *endlessArray) Read(p []byte) (int, error) {
+	for i := range p {
+		if r.n+int64(i) == 0 {
+			p[i] = '['
+		} else if (r.n+int64(i))%2 == 1 {
+			p[i] = '1'
+		} else {
+			p[i] = ','
+		}
+	}
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestLimitsStream(t *testing.T) {
+	// MaxBytes stops reading the endless value.
+	r := &endlessArray{}
+	dec := NewDecoder(r)
+	dec.SetLimits(Limits{MaxBytes: 1024})
+	var v []int
+	err := dec.Decode(&v)
+	want := &DecodeError{Line: 1, Column: 1, Err: &LimitError{Limit: "bytes", Max: 1024}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got %v, want %v", err, want)
+	}
+	if r.n > 1025 {
+		t.Errorf("read %d bytes, want at most 1025 bytes", r.n)
+	}
+
+	// the values within MaxBytes are decoded, even if they are followed by others
+	// or preceded by white spaces up to MaxBytes.
+	dec = NewDecoder(strings.NewReader("1 [2] 3\n  4"))
+	dec.SetLimits(Limits{MaxBytes: 3})
+	var got []interface{}
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, []interface{}{1.0, []interface{}{2.0}, 3.0, 4.0}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestDefaultMaxSliceIndex(t *testing.T) {
+	// Unmarshal can't set the limits, so the default protects it from huge slices.
+	err := Unmarshal([]byte(`{"999999999":1}`), &[]int64{})
+	want := &DecodeError{Path: `["999999999"]`, Offset: 1, Line: 1, Column: 2, Err: &LimitError{Limit: "slice index", Max: 65535}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got %v, want %v", err, want)
+	}
+
+	// the default grows with the size of the JSON value.
+	var v struct {
+		S   []int
+		Pad string
+	}
+	in := `{"S":{"100000":1},"Pad":"` + strings.Repeat("x", 100000) + `"}`
+	if err := Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.S) != 100001 || v.S[100000] != 1 {
+		t.Errorf("got len %d", len(v.S))
+	}
+}
+
+func TestZeroOnNull(t *testing.T) {
+	type inner struct {
+		A int
+	}
+	type prefilled struct {
+		Int    int
+		Uint   uint8
+		Float  float64
+		String string
+		Bool   bool
+		Array  [2]int
+		Struct inner
+		Time   time.Time
+		PHP    Array
+		Slice  []int
+		Map    map[string]int
+		Ptr    *int
+		Raw    RawMessage
+	}
+	in := `{"Int":null,"Uint":null,"Float":null,"String":null,"Bool":null,"Array":null,"Struct":null,"Time":null,"PHP":null,"Slice":null,"Map":null,"Ptr":null,"Raw":null}`
+	one := 1
+	fill := func() prefilled {
+		return prefilled{
+			Int:    1,
+			Uint:   1,
+			Float:  1,
+			String: "a",
+			Bool:   true,
+			Array:  [2]int{1, 2},
+			Struct: inner{A: 1},
+			Time:   time.Unix(1, 0),
+			PHP:    *NewArray(1),
+			Slice:  []int{1},
+			Map:    map[string]int{"a": 1},
+			Ptr:    &one,
+			Raw:    RawMessage(`1`),
+		}
+	}
+
+	// by default, null is ignored for primitives, arrays and structs.
+	got := fill()
+	if err := Unmarshal([]byte(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := fill()
+	want.Slice, want.Map, want.Ptr, want.Raw = nil, nil, nil, RawMessage(`null`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	got = fill()
+	dec := NewDecoder(strings.NewReader(in))
+	dec.ZeroOnNull()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// Unmarshalers handle null by themselves.
+	want = prefilled{Time: time.Unix(1, 0), Raw: RawMessage(`null`)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	// elements of arrays and values of maps
+	arr := [2]string{"a", "b"}
+	m := map[string]int{"a": 1}
+	dec = NewDecoder(strings.NewReader(`[null] {"a":null}`))
+	dec.ZeroOnNull()
+	if err := dec.Decode(&arr); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if arr != [2]string{} {
+		t.Errorf("got %#v, want %#v", arr, [2]string{})
+	}
+	if !reflect.DeepEqual(m, map[string]int{"a": 0}) {
+		t.Errorf("got %#v, want %#v", m, map[string]int{"a": 0})
+	}
+}
+
 type unmarshalPanic struct{}
 
 func (unmarshalPanic) UnmarshalJSON([]byte) error { panic(0xdead) }
@@ -7,13 +7,19 @@ package phperjson
 
 import (
 	"bytes"
+	"encoding"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Compact is an alias for json.Compact.
@@ -31,27 +37,9 @@ func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
 	return json.Indent(dst, src, prefix, indent)
 }
 
-// Marshal is an alias for json.Marshal.
-func Marshal(v interface{}) ([]byte, error) {
-	return json.Marshal(v)
-}
-
-// MarshalIndent is an alias for json.MarshalIndent.
-func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
-	return json.MarshalIndent(v, prefix, indent)
-}
-
 // Delim is an alias for json.Delim.
 type Delim = json.Delim
 
-// Encoder is an alias for json.Encoder.
-type Encoder = json.Encoder
-
-// NewEncoder is an alias for json.NewEncoder.
-func NewEncoder(w io.Writer) *Encoder {
-	return json.NewEncoder(w)
-}
-
 // InvalidUTF8Error is an alias for json.InvalidUTF8Error.
 type InvalidUTF8Error = json.InvalidUTF8Error
 
@@ -76,6 +64,1181 @@ type SyntaxError = json.SyntaxError
 // Token is an alias for json.Token.
 type Token = json.Token
 
+// Marshal returns the JSON encoding of v.
+//
+// phperjson.Marshal works in the same way as json.Marshal,
+// but it encodes Go values in the same way as PHP's json_encode
+// for the values PHP consumers care about.
+// Unlike json.Marshal, nil slices are encoded as [], because PHP doesn't distinguish null arrays from empty arrays,
+// and invalid UTF-8 strings cause an *InvalidUTF8Error.
+// Array is encoded into a JSON array or a JSON object in the same way as PHP arrays.
+//
+// Types implementing Marshaler or encoding.TextMarshaler are encoded in the same way as json.Marshal.
+//
+// In addition to the options of encoding/json, the json struct tag supports the following options
+// for the PHP consumers. They apply to the value of the field, including its elements.
+//
+//	// The lists are encoded into JSON objects, as EncodeForceObject.
+//	Field []int `json:",forceobject"`
+//
+//	// Nil and empty maps are encoded into [], as EncodeEmptyMapAsArray.
+//	Field map[string]int `json:",emptyarray"`
+//
+//	// The numbers are encoded into JSON strings, such as "42",
+//	// and the strings are not converted into numbers by EncodeNumericCheck.
+//	Field int64 `json:",numericstring"`
+//
+//	// The booleans are encoded into 1 and 0.
+//	Field bool `json:",boolint"`
+func Marshal(v interface{}) ([]byte, error) {
+	return MarshalFlags(v, 0)
+}
+
+// MarshalFlags is like Marshal but applies the flags, which mirror the flags of PHP's json_encode.
+// If flags contains EncodePartialOutputOnError, MarshalFlags returns the output
+// together with the first error of the values it could not encode.
+func MarshalFlags(v interface{}, flags EncodeFlag) ([]byte, error) {
+	e := &encodeState{flags: flags, escapeHTML: true}
+	if err := e.marshal(v); err != nil {
+		return nil, err
+	}
+	if flags&EncodePrettyPrint != 0 {
+		var buf bytes.Buffer
+		if err := Indent(&buf, e.Bytes(), "", phpIndent); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), e.err
+	}
+	return e.Bytes(), e.err
+}
+
+// phpIndent is the indent of JSON_PRETTY_PRINT.
+const phpIndent = "    "
+
+// MarshalIndent is like Marshal but applies Indent to format the output.
+// Each JSON element in the output will begin on a new line beginning with prefix
+// followed by one or more copies of indent according to the indentation nesting.
+func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	b, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeFlag is a set of the options of the encoder, which mirror the flags of PHP's json_encode.
+// The values are same as the JSON_* constants of PHP.
+// See https://www.php.net/manual/en/json.constants.php for more detail.
+type EncodeFlag int
+
+const (
+	// EncodeHexTag escapes < and > as \u003C and \u003E.
+	// It is JSON_HEX_TAG in PHP, and takes effect with EncodePHPCompatible.
+	EncodeHexTag EncodeFlag = 1
+
+	// EncodeHexAmp escapes & as \u0026.
+	// It is JSON_HEX_AMP in PHP, and takes effect with EncodePHPCompatible.
+	EncodeHexAmp EncodeFlag = 2
+
+	// EncodeHexApos escapes ' as \u0027.
+	// It is JSON_HEX_APOS in PHP, and takes effect with EncodePHPCompatible.
+	EncodeHexApos EncodeFlag = 4
+
+	// EncodeHexQuot escapes " as \u0022.
+	// It is JSON_HEX_QUOT in PHP, and takes effect with EncodePHPCompatible.
+	EncodeHexQuot EncodeFlag = 8
+
+	// EncodeForceObject encodes slices, arrays and lists into JSON objects with the indexes as the keys,
+	// instead of JSON arrays. It is JSON_FORCE_OBJECT in PHP.
+	EncodeForceObject EncodeFlag = 16
+
+	// EncodeNumericCheck encodes numeric strings, such as "123" and "1.5", into JSON numbers.
+	// It is JSON_NUMERIC_CHECK in PHP.
+	EncodeNumericCheck EncodeFlag = 32
+
+	// EncodeUnescapedSlashes doesn't escape /.
+	// It is JSON_UNESCAPED_SLASHES in PHP, and takes effect with EncodePHPCompatible.
+	EncodeUnescapedSlashes EncodeFlag = 64
+
+	// EncodePrettyPrint indents the output with 4 spaces.
+	// It is JSON_PRETTY_PRINT in PHP.
+	EncodePrettyPrint EncodeFlag = 128
+
+	// EncodeUnescapedUnicode writes multibyte characters literally instead of escaping them as \uXXXX.
+	// It is JSON_UNESCAPED_UNICODE in PHP, and takes effect with EncodePHPCompatible.
+	EncodeUnescapedUnicode EncodeFlag = 256
+
+	// EncodePartialOutputOnError substitutes the values that can't be encoded, instead of failing.
+	// Invalid UTF-8 strings, unsupported types, cycles and the values whose MarshalJSON or MarshalText fails
+	// are encoded as null, and NaN and infinities as 0.
+	// It is JSON_PARTIAL_OUTPUT_ON_ERROR in PHP.
+	EncodePartialOutputOnError EncodeFlag = 512
+
+	// EncodePreserveZeroFraction encodes floating point numbers with zero fractions as 10.0 instead of 10.
+	// It is JSON_PRESERVE_ZERO_FRACTION in PHP.
+	EncodePreserveZeroFraction EncodeFlag = 1024
+
+	// EncodeUnescapedLineTerminators writes U+2028 and U+2029 literally with EncodeUnescapedUnicode.
+	// It is JSON_UNESCAPED_LINE_TERMINATORS in PHP, and takes effect with EncodePHPCompatible.
+	EncodeUnescapedLineTerminators EncodeFlag = 2048
+
+	// EncodeInvalidUTF8Ignore drops invalid UTF-8 bytes in strings.
+	// It is JSON_INVALID_UTF8_IGNORE in PHP.
+	EncodeInvalidUTF8Ignore EncodeFlag = 0x100000
+
+	// EncodeInvalidUTF8Substitute replaces invalid UTF-8 bytes in strings with U+FFFD.
+	// It is JSON_INVALID_UTF8_SUBSTITUTE in PHP.
+	EncodeInvalidUTF8Substitute EncodeFlag = 0x200000
+
+	// EncodeLegacyPrecision formats floating point numbers with serialize_precision = 17,
+	// which is the default of PHP 7.0 and earlier, instead of -1.
+	// For example, 0.1 is encoded as 0.10000000000000001.
+	// It is not a flag of PHP, and takes effect with EncodePHPCompatible.
+	EncodeLegacyPrecision EncodeFlag = 1 << 28
+
+	// EncodePHPCompatible outputs the same bytes as PHP's json_encode,
+	// instead of escaping strings and formatting numbers in the same way as encoding/json.
+	// PHP escapes / as \/ and multibyte characters as \uXXXX, doesn't escape HTML characters,
+	// and encodes 1e20 as 1.0e+20.
+	// It is not a flag of PHP.
+	EncodePHPCompatible EncodeFlag = 1 << 29
+
+	// EncodeEmptyMapAsArray encodes nil and empty maps into [] instead of null and {},
+	// in the same way as empty PHP arrays.
+	// It is not a flag of PHP.
+	EncodeEmptyMapAsArray EncodeFlag = 1 << 30
+)
+
+// The flags enabled by the options of the json tag.
+// They are not exported, and set only for the values of the fields.
+const (
+	// encodeNumericString encodes numbers into JSON strings,
+	// and keeps strings as they are even with EncodeNumericCheck.
+	encodeNumericString EncodeFlag = 1 << 26
+
+	// encodeBoolInt encodes booleans into 1 and 0.
+	encodeBoolInt EncodeFlag = 1 << 27
+)
+
+// An Encoder writes JSON values to an output stream.
+type Encoder struct {
+	w          io.Writer
+	flags      EncodeFlag
+	escapeHTML bool
+	prefix     string
+	indent     string
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, escapeHTML: true}
+}
+
+// Encode writes the JSON encoding of v to the stream, followed by a newline character.
+//
+// See the documentation for Marshal for details about the conversion of Go values to JSON.
+func (enc *Encoder) Encode(v interface{}) error {
+	e := &encodeState{flags: enc.flags, escapeHTML: enc.escapeHTML}
+	if err := e.marshal(v); err != nil {
+		return err
+	}
+	e.WriteByte('\n')
+
+	b := e.Bytes()
+	prefix, indent := enc.prefix, enc.indent
+	if enc.flags&EncodePrettyPrint != 0 && prefix == "" && indent == "" {
+		indent = phpIndent
+	}
+	if prefix != "" || indent != "" {
+		var buf bytes.Buffer
+		if err := Indent(&buf, b, prefix, indent); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	if _, err := enc.w.Write(b); err != nil {
+		return err
+	}
+	return e.err
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded value as if indented by the package-level function Indent.
+// Calling SetIndent("", "") disables indentation.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.prefix = prefix
+	enc.indent = indent
+}
+
+// SetEscapeHTML specifies whether problematic HTML characters should be escaped inside JSON quoted strings.
+// The default behavior is to escape &, <, and > to \u0026, \u003c, and \u003e.
+func (enc *Encoder) SetEscapeHTML(on bool) {
+	enc.escapeHTML = on
+}
+
+// SetFlags sets the flags of the encoder, which mirror the flags of PHP's json_encode.
+func (enc *Encoder) SetFlags(flags EncodeFlag) {
+	enc.flags = flags
+}
+
+var (
+	marshalerType     = reflect.TypeOf(new(Marshaler)).Elem()
+	textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+	numberType        = reflect.TypeOf(Number(""))
+)
+
+// encodeState encodes JSON into a bytes.Buffer.
+type encodeState struct {
+	bytes.Buffer
+	flags      EncodeFlag
+	escapeHTML bool
+	err        error // the first error in the partial output mode
+
+	// Keep track of what pointers we've seen in the current recursive call
+	// path, to avoid cycles that could lead to a stack overflow. Only do
+	// the relatively expensive map operations if ptrLevel is larger than
+	// startDetectingCyclesAfter, so that we skip the work if we're within a
+	// reasonable amount of nested pointers deep.
+	ptrLevel uint
+	ptrSeen  map[interface{}]struct{}
+}
+
+const startDetectingCyclesAfter = 1000
+
+// jsonError is an error wrapper type for internal use only.
+// Panics with errors are wrapped in jsonError so that the top-level recover
+// can distinguish intentional panics from this package.
+type jsonError struct{ error }
+
+// marshal encodes v into e.
+// It returns the error that aborts encoding,
+// and the errors in the partial output mode are recorded in e.err.
+func (e *encodeState) marshal(v interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if je, ok := r.(jsonError); ok {
+				err = je.error
+			} else {
+				panic(r)
+			}
+		}
+	}()
+	e.reflectValue(reflect.ValueOf(v), encOpts{})
+	return nil
+}
+
+// fail reports the error err of encoding a value.
+// In the partial output mode, it writes substitute instead of the value and continues encoding,
+// otherwise it aborts encoding.
+func (e *encodeState) fail(err error, substitute string) {
+	if e.flags&EncodePartialOutputOnError == 0 {
+		panic(jsonError{err})
+	}
+	if e.err == nil {
+		e.err = err
+	}
+	e.WriteString(substitute)
+}
+
+// enterCycle checks that the encoder doesn't go into the cycle via the pointer ptr.
+// It returns false if the cycle is detected.
+func (e *encodeState) enterCycle(ptr interface{}, v reflect.Value) bool {
+	if e.ptrLevel++; e.ptrLevel <= startDetectingCyclesAfter {
+		return true
+	}
+	if _, ok := e.ptrSeen[ptr]; ok {
+		e.ptrLevel--
+		e.fail(&UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}, "null")
+		return false
+	}
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[interface{}]struct{})
+	}
+	e.ptrSeen[ptr] = struct{}{}
+	return true
+}
+
+// leaveCycle is the counterpart of enterCycle.
+func (e *encodeState) leaveCycle(ptr interface{}) {
+	if e.ptrLevel > startDetectingCyclesAfter {
+		delete(e.ptrSeen, ptr)
+	}
+	e.ptrLevel--
+}
+
+func (e *encodeState) reflectValue(v reflect.Value, opts encOpts) {
+	valueEncoder(v)(e, v, opts)
+}
+
+type encOpts struct {
+	// quoted causes primitive fields to be encoded inside JSON strings.
+	quoted bool
+}
+
+type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
+
+var encoderCache sync.Map // map[reflect.Type]encoderFunc
+
+func valueEncoder(v reflect.Value) encoderFunc {
+	if !v.IsValid() {
+		return invalidValueEncoder
+	}
+	return typeEncoder(v.Type())
+}
+
+func typeEncoder(t reflect.Type) encoderFunc {
+	if fi, ok := encoderCache.Load(t); ok {
+		return fi.(encoderFunc)
+	}
+
+	// To deal with recursive types, populate the map with an
+	// indirect func before we build it. This type waits on the
+	// real func (f) to be ready and then calls it. This indirect
+	// func is only used for recursive types.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value, opts encOpts) {
+		wg.Wait()
+		f(e, v, opts)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	// Compute the real encoder and replace the indirect func with it.
+	f = newTypeEncoder(t, true)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+// newTypeEncoder constructs an encoderFunc for a type.
+// The returned encoder only checks CanAddr when allowAddr is true.
+func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	// Array implements Marshaler, but it is encoded natively
+	// so that the flags of the encoder apply to its elements.
+	if t == arrayType {
+		return phpArrayEncoder
+	}
+	if t.Kind() == reflect.Ptr && t.Elem() == arrayType {
+		return newPtrEncoder(t)
+	}
+	if t == phpObjectType {
+		return phpObjectEncoder
+	}
+	if optionalTypes[t] {
+		return optionalEncoder
+	}
+	if t.Kind() == reflect.Ptr && optionalTypes[t.Elem()] {
+		return newPtrEncoder(t)
+	}
+
+	// If we have a non-pointer value whose type implements
+	// Marshaler with a value receiver, then we're better off taking
+	// the address of the value - otherwise we end up with an
+	// allocation as we cast the value to an interface.
+	if t.Kind() != reflect.Ptr && allowAddr && reflect.PtrTo(t).Implements(marshalerType) {
+		return newCondAddrEncoder(addrMarshalerEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(marshalerType) {
+		return marshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr && reflect.PtrTo(t).Implements(textMarshalerType) {
+		return newCondAddrEncoder(addrTextMarshalerEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(textMarshalerType) {
+		return textMarshalerEncoder
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintEncoder
+	case reflect.Float32, reflect.Float64:
+		return floatEncoder
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Slice:
+		return newSliceEncoder(t)
+	case reflect.Array:
+		return newArrayEncoder(t)
+	case reflect.Ptr:
+		return newPtrEncoder(t)
+	default:
+		return unsupportedTypeEncoder
+	}
+}
+
+func invalidValueEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	e.WriteString("null")
+}
+
+func marshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, ok := v.Interface().(Marshaler)
+	if !ok {
+		e.WriteString("null")
+		return
+	}
+	e.marshalJSON(m, v.Type())
+}
+
+func addrMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m := va.Interface().(Marshaler)
+	e.marshalJSON(m, v.Type())
+}
+
+// marshalJSON writes the compacted output of m.MarshalJSON.
+func (e *encodeState) marshalJSON(m Marshaler, t reflect.Type) {
+	b, err := m.MarshalJSON()
+	var buf bytes.Buffer
+	if err == nil {
+		err = Compact(&buf, b)
+	}
+	if err != nil {
+		e.fail(&MarshalerError{Type: t, Err: err}, "null")
+		return
+	}
+	if e.escapeHTML && e.flags&EncodePHPCompatible == 0 {
+		HTMLEscape(&e.Buffer, buf.Bytes())
+	} else {
+		e.Write(buf.Bytes())
+	}
+}
+
+func textMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		e.WriteString("null")
+		return
+	}
+	e.marshalText(m, v.Type())
+}
+
+func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m := va.Interface().(encoding.TextMarshaler)
+	e.marshalText(m, v.Type())
+}
+
+// marshalText writes the output of m.MarshalText as a JSON string.
+func (e *encodeState) marshalText(m encoding.TextMarshaler, t reflect.Type) {
+	b, err := m.MarshalText()
+	if err != nil {
+		e.fail(&MarshalerError{Type: t, Err: err}, "null")
+		return
+	}
+	e.string(string(b), "null")
+}
+
+func boolEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if opts.quoted {
+		e.WriteByte('"')
+	}
+	switch b := v.Bool(); {
+	case e.flags&encodeBoolInt != 0 && b:
+		e.WriteByte('1')
+	case e.flags&encodeBoolInt != 0:
+		e.WriteByte('0')
+	case b:
+		e.WriteString("true")
+	default:
+		e.WriteString("false")
+	}
+	if opts.quoted {
+		e.WriteByte('"')
+	}
+}
+
+func intEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	var scratch [64]byte
+	b := strconv.AppendInt(scratch[:0], v.Int(), 10)
+	quoted := opts.quoted || e.flags&encodeNumericString != 0
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+func uintEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	var scratch [64]byte
+	b := strconv.AppendUint(scratch[:0], v.Uint(), 10)
+	quoted := opts.quoted || e.flags&encodeNumericString != 0
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+func floatEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	quoted := opts.quoted || e.flags&encodeNumericString != 0
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.float(v.Float(), v.Type().Bits())
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+// float writes the floating point number f of the bit size bits.
+func (e *encodeState) float(f float64, bits int) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		// PHP encodes them into 0 with JSON_PARTIAL_OUTPUT_ON_ERROR.
+		e.fail(&UnsupportedValueError{Value: reflect.ValueOf(f), Str: strconv.FormatFloat(f, 'g', -1, bits)}, "0")
+		return
+	}
+	if e.flags&EncodePHPCompatible != 0 {
+		e.phpFloat(f, bits)
+		return
+	}
+
+	// Convert as if by ES6 number to string conversion.
+	// This matches most other JSON generators.
+	// See golang.org/issue/6384 and golang.org/issue/14135.
+	// Like fmt %g, but the exponent cutoffs are different
+	// and exponents themselves are not padded to two digits.
+	var scratch [64]byte
+	b := scratch[:0]
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmt = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, fmt, -1, bits)
+	if fmt == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	} else if e.flags&EncodePreserveZeroFraction != 0 && bytes.IndexByte(b, '.') < 0 {
+		b = append(b, '.', '0')
+	}
+	e.Write(b)
+}
+
+func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Type() == numberType {
+		numStr := v.String()
+		// In Go1.5 the empty string encodes to "0", while this is not a valid number literal
+		// we keep compatibility so check validity after this.
+		if numStr == "" {
+			numStr = "0" // Number's zero-val
+		}
+		if !isValidNumber(numStr) {
+			panic(jsonError{fmt.Errorf("json: invalid number literal %q", numStr)})
+		}
+		quoted := opts.quoted || e.flags&encodeNumericString != 0
+		if quoted {
+			e.WriteByte('"')
+		}
+		e.WriteString(numStr)
+		if quoted {
+			e.WriteByte('"')
+		}
+		return
+	}
+	if opts.quoted {
+		var buf encodeState
+		buf.flags = e.flags
+		buf.escapeHTML = e.escapeHTML
+		buf.string(v.String(), "null")
+		e.string(buf.String(), "null")
+		return
+	}
+	if e.flags&(EncodeNumericCheck|encodeNumericString) == EncodeNumericCheck && e.numeric(v.String()) {
+		return
+	}
+	e.string(v.String(), "null")
+}
+
+// numeric writes the PHP numeric string s as a JSON number, and reports whether s is numeric.
+// See https://www.php.net/manual/en/function.is-numeric.php
+func (e *encodeState) numeric(s string) bool {
+	num, rest, ok := scanNumericString([]byte(s))
+	for len(rest) > 0 && isPHPSpace(rest[0]) {
+		rest = rest[1:]
+	}
+	if !ok || len(rest) != 0 {
+		return false
+	}
+	if n, err := strconv.ParseInt(string(num), 10, 64); err == nil {
+		e.WriteString(strconv.FormatInt(n, 10))
+		return true
+	}
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil && !math.IsInf(f, 0) {
+		return false
+	}
+	e.float(f, 64)
+	return true
+}
+
+// isValidNumber reports whether s is a valid JSON number literal.
+func isValidNumber(s string) bool {
+	// This function implements the JSON numbers grammar.
+	// See https://tools.ietf.org/html/rfc7159#section-6
+	// and https://www.json.org/img/number.png
+
+	if s == "" {
+		return false
+	}
+
+	// Optional -
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+
+	// Digits
+	switch {
+	default:
+		return false
+
+	case s[0] == '0':
+		s = s[1:]
+
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// . followed by 1 or more digits.
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// e or E followed by an optional - or + and
+	// 1 or more digits.
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// Make sure we are at the end.
+	return s == ""
+}
+
+func interfaceEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	e.reflectValue(v.Elem(), opts)
+}
+
+func unsupportedTypeEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	e.fail(&UnsupportedTypeError{Type: v.Type()}, "null")
+}
+
+type structEncoder struct {
+	fields   structFields
+	encoders []encoderFunc
+}
+
+func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	next := byte('{')
+FieldLoop:
+	for i := range se.fields.list {
+		f := &se.fields.list[i]
+
+		// Find the nested struct field by following f.index.
+		fv := v
+		for _, i := range f.index {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue FieldLoop
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(i)
+		}
+
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		e.WriteByte(next)
+		next = ','
+		e.string(f.name, `""`)
+		e.WriteByte(':')
+		if f.encodeFlags == 0 {
+			se.encoders[i](e, fv, encOpts{quoted: f.quoted})
+			continue
+		}
+		// the options of the json tag apply to the value of the field, including its elements.
+		flags := e.flags
+		e.flags |= f.encodeFlags
+		se.encoders[i](e, fv, encOpts{quoted: f.quoted})
+		e.flags = flags
+	}
+	if next == '{' {
+		e.WriteString("{}")
+	} else {
+		e.WriteByte('}')
+	}
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields := cachedTypeFields(t)
+	se := structEncoder{
+		fields:   fields,
+		encoders: make([]encoderFunc, len(fields.list)),
+	}
+	for i, f := range fields.list {
+		se.encoders[i] = typeEncoder(typeByIndex(t, f.index))
+	}
+	return se.encode
+}
+
+// typeByIndex returns the type of the nested struct field of t by following index.
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == arrayType {
+			return len(v.Field(0).Interface().([]interface{})) == 0
+		}
+		if optionalTypes[v.Type()] {
+			return !v.Field(0).Bool()
+		}
+	}
+	return false
+}
+
+// emptyArray writes the empty PHP array.
+// If object is true, the empty array is encoded as {} unless EncodeEmptyMapAsArray is specified.
+func (e *encodeState) emptyArray(object bool) {
+	if e.flags&EncodeForceObject != 0 || object && e.flags&EncodeEmptyMapAsArray == 0 {
+		e.WriteString("{}")
+	} else {
+		e.WriteString("[]")
+	}
+}
+
+type mapEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (me mapEncoder) encode(e *encodeState, v reflect.Value, _ encOpts) {
+	if v.IsNil() && e.flags&EncodeEmptyMapAsArray == 0 {
+		e.WriteString("null")
+		return
+	}
+	if v.Len() == 0 {
+		e.emptyArray(true)
+		return
+	}
+	ptr := v.Pointer()
+	if !e.enterCycle(ptr, v) {
+		return
+	}
+	defer e.leaveCycle(ptr)
+
+	// Extract and sort the keys.
+	keys := v.MapKeys()
+	sv := make([]reflectWithString, len(keys))
+	for i, k := range keys {
+		sv[i].v = k
+		if err := sv[i].resolve(); err != nil {
+			panic(jsonError{&MarshalerError{Type: k.Type(), Err: err}})
+		}
+	}
+	sort.Slice(sv, func(i, j int) bool { return sv[i].s < sv[j].s })
+
+	e.WriteByte('{')
+	for i, kv := range sv {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		e.string(kv.s, `""`)
+		e.WriteByte(':')
+		me.elemEnc(e, v.MapIndex(kv.v), encOpts{})
+	}
+	e.WriteByte('}')
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !t.Key().Implements(textMarshalerType) {
+			return unsupportedTypeEncoder
+		}
+	}
+	me := mapEncoder{typeEncoder(t.Elem())}
+	return me.encode
+}
+
+type reflectWithString struct {
+	v reflect.Value
+	s string
+}
+
+func (w *reflectWithString) resolve() error {
+	if w.v.Kind() == reflect.String {
+		w.s = w.v.String()
+		return nil
+	}
+	if tm, ok := w.v.Interface().(encoding.TextMarshaler); ok {
+		if w.v.Kind() == reflect.Ptr && w.v.IsNil() {
+			return nil
+		}
+		buf, err := tm.MarshalText()
+		w.s = string(buf)
+		return err
+	}
+	switch w.v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.s = strconv.FormatInt(w.v.Int(), 10)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.s = strconv.FormatUint(w.v.Uint(), 10)
+		return nil
+	}
+	panic("unexpected map key type")
+}
+
+func encodeByteSlice(e *encodeState, v reflect.Value, _ encOpts) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	s := v.Bytes()
+	e.WriteByte('"')
+	encodedLen := base64.StdEncoding.EncodedLen(len(s))
+	dst := make([]byte, encodedLen)
+	base64.StdEncoding.Encode(dst, s)
+	e.Write(dst)
+	e.WriteByte('"')
+}
+
+// sliceEncoder just wraps an arrayEncoder, checking to make sure the value isn't nil.
+type sliceEncoder struct {
+	arrayEnc encoderFunc
+}
+
+func (se sliceEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Len() == 0 {
+		// PHP doesn't distinguish null arrays from empty arrays.
+		e.emptyArray(false)
+		return
+	}
+	// Here we use a struct to memorize the pointer to the first element of the slice
+	// and its length.
+	ptr := struct {
+		ptr uintptr
+		len int
+	}{v.Pointer(), v.Len()}
+	if !e.enterCycle(ptr, v) {
+		return
+	}
+	defer e.leaveCycle(ptr)
+	se.arrayEnc(e, v, opts)
+}
+
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	// Byte slices get special treatment; arrays don't.
+	if t.Elem().Kind() == reflect.Uint8 {
+		p := reflect.PtrTo(t.Elem())
+		if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) {
+			return encodeByteSlice
+		}
+	}
+	enc := sliceEncoder{newArrayEncoder(t)}
+	return enc.encode
+}
+
+type arrayEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (ae arrayEncoder) encode(e *encodeState, v reflect.Value, _ encOpts) {
+	n := v.Len()
+	if n == 0 {
+		e.emptyArray(false)
+		return
+	}
+	object := e.flags&EncodeForceObject != 0
+	if object {
+		e.WriteByte('{')
+	} else {
+		e.WriteByte('[')
+	}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		if object {
+			e.WriteByte('"')
+			e.WriteString(strconv.Itoa(i))
+			e.WriteString(`":`)
+		}
+		ae.elemEnc(e, v.Index(i), encOpts{})
+	}
+	if object {
+		e.WriteByte('}')
+	} else {
+		e.WriteByte(']')
+	}
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	enc := arrayEncoder{typeEncoder(t.Elem())}
+	return enc.encode
+}
+
+// phpArrayEncoder encodes Array in the same way as PHP's json_encode.
+func phpArrayEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+	a := v.Interface().(Array)
+	e.phpArray(&a, false)
+}
+
+// phpArray writes the PHP array a.
+// A list is encoded into a JSON array, and other arrays are encoded into JSON objects.
+// If object is true, a is always encoded into a JSON object, as PHP objects are.
+func (e *encodeState) phpArray(a *Array, object bool) {
+	if a.Len() == 0 {
+		if object {
+			e.WriteString("{}")
+		} else {
+			e.emptyArray(false)
+		}
+		return
+	}
+	object = object || e.flags&EncodeForceObject != 0 || !a.IsList()
+	if object {
+		e.WriteByte('{')
+	} else {
+		e.WriteByte('[')
+	}
+	for i, k := range a.keys {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		if object {
+			e.string(arrayKeyString(k), `""`)
+			e.WriteByte(':')
+		}
+		e.reflectValue(reflect.ValueOf(a.values[i]), encOpts{})
+	}
+	if object {
+		e.WriteByte('}')
+	} else {
+		e.WriteByte(']')
+	}
+}
+
+type ptrEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (pe ptrEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	ptr := v.Interface()
+	if !e.enterCycle(ptr, v) {
+		return
+	}
+	defer e.leaveCycle(ptr)
+	pe.elemEnc(e, v.Elem(), opts)
+}
+
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	enc := ptrEncoder{typeEncoder(t.Elem())}
+	return enc.encode
+}
+
+type condAddrEncoder struct {
+	canAddrEnc, elseEnc encoderFunc
+}
+
+func (ce condAddrEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.CanAddr() {
+		ce.canAddrEnc(e, v, opts)
+	} else {
+		ce.elseEnc(e, v, opts)
+	}
+}
+
+// newCondAddrEncoder returns an encoder that checks whether its value
+// CanAddr and delegates to canAddrEnc if so, else to elseEnc.
+func newCondAddrEncoder(canAddrEnc, elseEnc encoderFunc) encoderFunc {
+	enc := condAddrEncoder{canAddrEnc: canAddrEnc, elseEnc: elseEnc}
+	return enc.encode
+}
+
+const hex = "0123456789abcdef"
+
+// string writes s as a JSON string.
+// If s is not valid UTF-8, it follows the flags of e, and writes substitute in the partial output mode.
+func (e *encodeState) string(s string, substitute string) {
+	invalid := e.flags & (EncodeInvalidUTF8Ignore | EncodeInvalidUTF8Substitute)
+	if invalid == 0 && !utf8.ValidString(s) {
+		e.fail(&InvalidUTF8Error{S: s}, substitute)
+		return
+	}
+	if e.flags&EncodePHPCompatible != 0 {
+		e.phpString(s)
+		return
+	}
+
+	e.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && (!e.escapeHTML || b != '<' && b != '>' && b != '&') {
+				i++
+				continue
+			}
+			if start < i {
+				e.WriteString(s[start:i])
+			}
+			e.WriteByte('\\')
+			switch b {
+			case '\\', '"':
+				e.WriteByte(b)
+			case '\b':
+				e.WriteByte('b')
+			case '\f':
+				e.WriteByte('f')
+			case '\n':
+				e.WriteByte('n')
+			case '\r':
+				e.WriteByte('r')
+			case '\t':
+				e.WriteByte('t')
+			default:
+				// This encodes bytes < 0x20 except for \b, \f, \n, \r and \t.
+				// If escapeHTML is set, it also escapes <, >, and &
+				// because they can lead to security holes when
+				// user-controlled strings are rendered into JSON
+				// and served to some browsers.
+				e.WriteString(`u00`)
+				e.WriteByte(hex[b>>4])
+				e.WriteByte(hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			if start < i {
+				e.WriteString(s[start:i])
+			}
+			if invalid&EncodeInvalidUTF8Substitute != 0 {
+				e.WriteString(`\ufffd`)
+			}
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 is LINE SEPARATOR.
+		// U+2029 is PARAGRAPH SEPARATOR.
+		// They are both technically valid characters in JSON strings,
+		// but don't work in JSONP, which has to be evaluated as JavaScript,
+		// and can lead to security holes there. It is valid JSON to
+		// escape them, so we do so unconditionally.
+		// See http://timelessrepo.com/json-isnt-a-javascript-subset for discussion.
+		if c == '\u2028' || c == '\u2029' {
+			if start < i {
+				e.WriteString(s[start:i])
+			}
+			e.WriteString(`\u202`)
+			e.WriteByte(hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	if start < len(s) {
+		e.WriteString(s[start:])
+	}
+	e.WriteByte('"')
+}
+
 func isValidTag(s string) bool {
 	if s == "" {
 		return false
@@ -106,6 +1269,23 @@ type field struct {
 	typ       reflect.Type
 	omitEmpty bool
 	quoted    bool
+
+	encodeFlags EncodeFlag  // the encoder flags enabled by the tag options
+	embedPtr    bool        // index goes through embedded pointers
+	decode      decoderFunc // decodes the value of the field
+	juggle      bool        // the field has the juggle tag
+	noJuggling  Juggling    // the conversions disabled by the juggle tag
+	null        bool        // the field has the null tag
+	nullLike    NullLike    // the values treated as null by the null tag
+}
+
+// structFields is the list of fields of a struct type with the indexes
+// for looking up fields by their names.
+type structFields struct {
+	list         []field
+	byExactName  map[string]*field
+	byFoldedName map[string]*field
+	err          error // the first error in the decoder struct tags, such as unknown names in the juggle and null tags
 }
 
 func fillField(f field) field {
@@ -136,7 +1316,7 @@ func (x byIndex) Less(i, j int) bool {
 // typeFields returns a list of fields that JSON should recognize for the given type.
 // The algorithm is breadth-first search over the set of structs to include - the top struct
 // and then any reachable anonymous structs.
-func typeFields(t reflect.Type) []field {
+func typeFields(t reflect.Type) structFields {
 	// Anonymous fields to explore at the current level and the next.
 	current := []field{}
 	next := []field{{typ: t}}
@@ -151,6 +1331,9 @@ func typeFields(t reflect.Type) []field {
 	// Fields found.
 	var fields []field
 
+	// The first error in the decoder struct tags.
+	var tagErr error
+
 	for len(next) > 0 {
 		current, next = next, current[:0]
 		count, nextCount = nextCount, map[reflect.Type]int{}
@@ -217,13 +1400,30 @@ func typeFields(t reflect.Type) []field {
 					if name == "" {
 						name = sf.Name
 					}
+					juggle, hasJuggle := sf.Tag.Lookup("juggle")
+					juggling, err := parseJuggling(juggle)
+					if err != nil && tagErr == nil {
+						tagErr = fmt.Errorf("phperjson: invalid juggle tag of %s.%s: %v", f.typ, sf.Name, err)
+					}
+					null, hasNull := sf.Tag.Lookup("null")
+					nullLike, err := parseNullLike(null)
+					if err != nil && tagErr == nil {
+						tagErr = fmt.Errorf("phperjson: invalid null tag of %s.%s: %v", f.typ, sf.Name, err)
+					}
 					fields = append(fields, fillField(field{
-						name:      name,
-						tag:       tagged,
-						index:     index,
-						typ:       ft,
-						omitEmpty: opts.Contains("omitempty"),
-						quoted:    quoted,
+						name:        name,
+						tag:         tagged,
+						index:       index,
+						typ:         ft,
+						omitEmpty:   opts.Contains("omitempty"),
+						quoted:      quoted,
+						encodeFlags: opts.encodeFlags(),
+						embedPtr:    f.embedPtr,
+						decode:      typeDecoder(sf.Type),
+						juggle:      hasJuggle,
+						noJuggling:  JuggleAll &^ juggling,
+						null:        hasNull,
+						nullLike:    nullLike,
 					}))
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
@@ -238,7 +1438,12 @@ func typeFields(t reflect.Type) []field {
 				// Record new anonymous struct to explore in next round.
 				nextCount[ft]++
 				if nextCount[ft] == 1 {
-					next = append(next, fillField(field{name: ft.Name(), index: index, typ: ft}))
+					next = append(next, fillField(field{
+						name:     ft.Name(),
+						index:    index,
+						typ:      ft,
+						embedPtr: f.embedPtr || sf.Type.Kind() == reflect.Ptr,
+					}))
 				}
 			}
 		}
@@ -292,7 +1497,22 @@ func typeFields(t reflect.Type) []field {
 	fields = out
 	sort.Sort(byIndex(fields))
 
-	return fields
+	exactNameIndex := make(map[string]*field, len(fields))
+	foldedNameIndex := make(map[string]*field, len(fields))
+	for i, field := range fields {
+		exactNameIndex[field.name] = &fields[i]
+		// For historical reasons, first folded match takes precedence.
+		folded := string(appendFoldedName(nil, field.nameBytes))
+		if _, ok := foldedNameIndex[folded]; !ok {
+			foldedNameIndex[folded] = &fields[i]
+		}
+	}
+	return structFields{
+		list:         fields,
+		byExactName:  exactNameIndex,
+		byFoldedName: foldedNameIndex,
+		err:          tagErr,
+	}
 }
 
 // dominantField looks through the fields, all of which are known to
@@ -311,13 +1531,13 @@ func dominantField(fields []field) (field, bool) {
 	return fields[0], true
 }
 
-var fieldCache sync.Map // map[reflect.Type][]field
+var fieldCache sync.Map // map[reflect.Type]structFields
 
 // cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
-func cachedTypeFields(t reflect.Type) []field {
+func cachedTypeFields(t reflect.Type) structFields {
 	if f, ok := fieldCache.Load(t); ok {
-		return f.([]field)
+		return f.(structFields)
 	}
 	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
-	return f.([]field)
+	return f.(structFields)
 }
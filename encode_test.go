@@ -0,0 +1,246 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type encodeCycle struct {
+	Next *encodeCycle
+}
+
+type encodeMarshaler struct{}
+
+func (encodeMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "a" : [1, 2] }`), nil
+}
+
+type encodeMarshalerError struct{}
+
+func (encodeMarshalerError) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("failed")
+}
+
+type encodeTextMarshalerError struct{}
+
+func (encodeTextMarshalerError) MarshalText() ([]byte, error) {
+	return nil, errors.New("failed")
+}
+
+var encodeTests = []struct {
+	in    interface{}
+	flags EncodeFlag
+	out   string
+	err   error
+}{
+	// PHP doesn't distinguish null arrays from empty arrays.
+	{in: []int(nil), out: `[]`},
+	{in: []int{}, out: `[]`},
+	{in: []byte(nil), out: `null`},
+	{in: []byte("abc"), out: `"YWJj"`},
+	{in: map[string]int(nil), out: `null`},
+	{in: map[string]int{}, out: `{}`},
+	{in: map[string]int(nil), flags: EncodeEmptyMapAsArray, out: `[]`},
+	{in: map[string]int{}, flags: EncodeEmptyMapAsArray, out: `[]`},
+	{in: map[string]int{"b": 2, "a": 1}, flags: EncodeEmptyMapAsArray, out: `{"a":1,"b":2}`},
+	{in: struct{ A []string }{}, out: `{"A":[]}`},
+	{in: struct {
+		A []string `json:",omitempty"`
+	}{}, out: `{}`},
+
+	// Marshaler and TextMarshaler
+	{in: encodeMarshaler{}, out: `{"a":[1,2]}`},
+	{in: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC), out: `"2018-01-02T03:04:05Z"`},
+	{in: RawMessage(`[1, 2]`), out: `[1,2]`},
+	{in: Number("1.5"), out: `1.5`},
+	{in: struct {
+		N int `json:",string"`
+	}{N: 42}, out: `{"N":"42"}`},
+
+	// JSON_FORCE_OBJECT
+	{in: []int{1, 2}, flags: EncodeForceObject, out: `{"0":1,"1":2}`},
+	{in: [2]string{"a", "b"}, flags: EncodeForceObject, out: `{"0":"a","1":"b"}`},
+	{in: []int{}, flags: EncodeForceObject, out: `{}`},
+	{in: map[string]int{}, flags: EncodeForceObject | EncodeEmptyMapAsArray, out: `{}`},
+	{in: map[string][]int{"a": {1}}, flags: EncodeForceObject, out: `{"a":{"0":1}}`},
+	{in: NewArray("a", NewArray()), flags: EncodeForceObject, out: `{"0":"a","1":{}}`},
+
+	// JSON_NUMERIC_CHECK
+	{in: []string{"123", "-1.5", "1e3", " 12", "12 ", "0x1A", "12abc", "", "abc"}, flags: EncodeNumericCheck, out: `[123,-1.5,1000,12,12,"0x1A","12abc","","abc"]`},
+	{in: map[string]string{"007": "007"}, flags: EncodeNumericCheck, out: `{"007":7}`},
+	{in: "99999999999999999999", flags: EncodeNumericCheck, out: `100000000000000000000`},
+	{in: NewArray("1", "a"), flags: EncodeNumericCheck, out: `[1,"a"]`},
+	{in: struct {
+		S string `json:",string"`
+	}{S: "1"}, flags: EncodeNumericCheck, out: `{"S":"\"1\""}`},
+
+	// invalid UTF-8
+	{in: "a\xffb", err: &InvalidUTF8Error{S: "a\xffb"}},
+	{in: "a\xffb", flags: EncodeInvalidUTF8Ignore, out: `"ab"`},
+	{in: "a\xffb", flags: EncodeInvalidUTF8Substitute, out: `"a\ufffdb"`},
+	{in: map[string]string{"a\xff": "b"}, flags: EncodeInvalidUTF8Substitute, out: `{"a\ufffd":"b"}`},
+
+	// JSON_PARTIAL_OUTPUT_ON_ERROR
+	{in: []interface{}{"a\xffb", 1}, flags: EncodePartialOutputOnError, out: `[null,1]`, err: &InvalidUTF8Error{S: "a\xffb"}},
+	{in: []float64{math.Inf(1), 1}, flags: EncodePartialOutputOnError, out: `[0,1]`, err: &UnsupportedValueError{Value: reflect.ValueOf(math.Inf(1)), Str: "+Inf"}},
+	{in: map[string]interface{}{"a": make(chan int), "b": 1}, flags: EncodePartialOutputOnError, out: `{"a":null,"b":1}`, err: &UnsupportedTypeError{Type: reflect.TypeOf(make(chan int))}},
+	{in: make(chan int), err: &UnsupportedTypeError{Type: reflect.TypeOf(make(chan int))}},
+
+	// tag options
+	{in: struct {
+		A []int            `json:"a,forceobject"`
+		B []int            `json:"b,forceobject"`
+		C [][]string       `json:"c,forceobject"`
+		D []int            `json:"d"`
+		E map[string]int   `json:"e,emptyarray"`
+		F map[string]int   `json:"f,emptyarray"`
+		G map[string][]int `json:"g,emptyarray,forceobject"`
+	}{A: []int{1}, C: [][]string{{"x"}}, D: []int{2}, E: map[string]int{}, G: map[string][]int{"a": {3}}}, out: `{"a":{"0":1},"b":{},"c":{"0":{"0":"x"}},"d":[2],"e":[],"f":[],"g":{"a":{"0":3}}}`},
+	{in: struct {
+		ID  int64     `json:"id,numericstring"`
+		U   *uint     `json:"u,numericstring"`
+		F   float64   `json:"f,numericstring"`
+		N   Number    `json:"n,numericstring"`
+		IDs []int     `json:"ids,numericstring"`
+		S   string    `json:"s,numericstring"`
+		Q   int       `json:"q,string,numericstring"`
+		X   string    `json:"x"`
+		B   bool      `json:"b,boolint"`
+		BS  []bool    `json:"bs,boolint"`
+		BQ  bool      `json:"bq,string,boolint"`
+		M   *struct{} `json:"m,boolint,numericstring"`
+	}{ID: 42, F: 1.5, N: "7", IDs: []int{1, 2}, S: "10", Q: 3, X: "10", B: true, BS: []bool{true, false}}, flags: EncodeNumericCheck, out: `{"id":"42","u":null,"f":"1.5","n":"7","ids":["1","2"],"s":"10","q":"3","x":10,"b":1,"bs":[1,0],"bq":"0","m":null}`},
+
+	// JSON_PRESERVE_ZERO_FRACTION
+	{in: []float64{10, 1.5, 1e21}, flags: EncodePreserveZeroFraction, out: `[10.0,1.5,1e+21]`},
+
+	// JSON_PRETTY_PRINT
+	{in: []interface{}{1, map[string]interface{}{"a": []int{}}}, flags: EncodePrettyPrint, out: "[\n    1,\n    {\n        \"a\": []\n    }\n]"},
+
+	// PHP compatible strings
+	{in: "a/b<>&'\"\\\x1f\x7f", flags: EncodePHPCompatible, out: "\"a\\/b<>&'\\\"\\\\\\u001f\x7f\""},
+	{in: "<>&'\"", flags: EncodePHPCompatible | EncodeHexTag | EncodeHexAmp | EncodeHexApos | EncodeHexQuot, out: `"\u003C\u003E\u0026\u0027\u0022"`},
+	{in: "a/b", flags: EncodePHPCompatible | EncodeUnescapedSlashes, out: `"a/b"`},
+	{in: "é\u2028😀", flags: EncodePHPCompatible, out: `"\u00e9\u2028\ud83d\ude00"`},
+	{in: "é\u2028😀", flags: EncodePHPCompatible | EncodeUnescapedUnicode, out: "\"é\\u2028😀\""},
+	{in: "é\u2028😀", flags: EncodePHPCompatible | EncodeUnescapedUnicode | EncodeUnescapedLineTerminators, out: "\"é\u2028😀\""},
+	{in: "a\xffb", flags: EncodePHPCompatible | EncodeInvalidUTF8Substitute, out: `"a\ufffdb"`},
+	{in: "a\xffb", flags: EncodePHPCompatible | EncodeInvalidUTF8Substitute | EncodeUnescapedUnicode, out: "\"a\ufffdb\""},
+	{in: "a\xffb", flags: EncodePHPCompatible | EncodeInvalidUTF8Ignore, out: `"ab"`},
+	{in: map[string]string{"a/b": "c/d"}, flags: EncodePHPCompatible, out: `{"a\/b":"c\/d"}`},
+	{in: RawMessage(`"<a>"`), flags: EncodePHPCompatible, out: `"<a>"`},
+
+	// PHP compatible numbers
+	{in: 10.0, flags: EncodePHPCompatible, out: `10`},
+	{in: 10.0, flags: EncodePHPCompatible | EncodePreserveZeroFraction, out: `10.0`},
+	{in: 0.1, flags: EncodePHPCompatible, out: `0.1`},
+	{in: 0.1, flags: EncodePHPCompatible | EncodeLegacyPrecision, out: `0.10000000000000001`},
+	{in: 1.0 / 3, flags: EncodePHPCompatible | EncodeLegacyPrecision, out: `0.33333333333333331`},
+	{in: 100.0, flags: EncodePHPCompatible | EncodeLegacyPrecision, out: `100`},
+	{in: -1.5, flags: EncodePHPCompatible, out: `-1.5`},
+	{in: math.Copysign(0, -1), flags: EncodePHPCompatible, out: `-0`},
+	{in: 0.0, flags: EncodePHPCompatible | EncodePreserveZeroFraction, out: `0.0`},
+	{in: 1e15, flags: EncodePHPCompatible, out: `1000000000000000`},
+	{in: 1e17, flags: EncodePHPCompatible, out: `1.0e+17`},
+	{in: 1e17, flags: EncodePHPCompatible | EncodePreserveZeroFraction, out: `1.0e+17`},
+	{in: 1.25e20, flags: EncodePHPCompatible, out: `1.25e+20`},
+	{in: 0.0001, flags: EncodePHPCompatible, out: `0.0001`},
+	{in: 0.00001, flags: EncodePHPCompatible, out: `1.0e-5`},
+	{in: -1.5e-300, flags: EncodePHPCompatible, out: `-1.5e-300`},
+	{in: 123.456, flags: EncodePHPCompatible, out: `123.456`},
+	{in: float32(0.1), flags: EncodePHPCompatible, out: `0.1`},
+	{in: "1e20", flags: EncodePHPCompatible | EncodeNumericCheck, out: `1.0e+20`},
+	{in: "1.0", flags: EncodePHPCompatible | EncodeNumericCheck | EncodePreserveZeroFraction, out: `1.0`},
+}
+
+func TestMarshalFlags(t *testing.T) {
+	for i, tt := range encodeTests {
+		b, err := MarshalFlags(tt.in, tt.flags)
+		if !equalError(err, tt.err) {
+			t.Errorf("#%d: error got %#v, want %#v", i, err, tt.err)
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("#%d: got %s, want %s", i, b, tt.out)
+		}
+	}
+}
+
+// equalError reports whether the errors are the same.
+// UnsupportedValueError has reflect.Value, which can't be compared by reflect.DeepEqual.
+func equalError(a, b error) bool {
+	if a, ok := a.(*UnsupportedValueError); ok {
+		b, ok := b.(*UnsupportedValueError)
+		return ok && a.Str == b.Str
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func TestMarshalError(t *testing.T) {
+	_, err := Marshal(encodeMarshalerError{})
+	if _, ok := err.(*MarshalerError); !ok {
+		t.Errorf("got %#v, want *MarshalerError", err)
+	}
+
+	_, err = Marshal(encodeTextMarshalerError{})
+	if _, ok := err.(*MarshalerError); !ok {
+		t.Errorf("got %#v, want *MarshalerError", err)
+	}
+
+	// the partial output substitutes null for the values that Marshalers fail to encode.
+	in := map[string]interface{}{"a": encodeMarshalerError{}, "b": encodeTextMarshalerError{}, "c": 1}
+	b, err := MarshalFlags(in, EncodePartialOutputOnError)
+	if _, ok := err.(*MarshalerError); !ok || string(b) != `{"a":null,"b":null,"c":1}` {
+		t.Errorf("got %s, %#v, want *MarshalerError", b, err)
+	}
+}
+
+func TestMarshalCycle(t *testing.T) {
+	c := &encodeCycle{}
+	c.Next = c
+	if _, err := Marshal(c); err == nil {
+		t.Error("want error")
+	}
+
+	b, err := MarshalFlags(c, EncodePartialOutputOnError)
+	if _, ok := err.(*UnsupportedValueError); !ok {
+		t.Errorf("got %#v, want *UnsupportedValueError", err)
+	}
+	if !bytes.HasSuffix(b, []byte(`{"Next":null}`+string(bytes.Repeat([]byte{'}'}, startDetectingCyclesAfter)))) {
+		t.Errorf("unexpected output: %s", b)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetFlags(EncodeForceObject)
+	if err := enc.Encode([]string{"<a>"}); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode([]string{"<a>"}); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetFlags(0)
+	if err := enc.Encode("\xff"); err == nil {
+		t.Error("want error")
+	}
+	want := `{"0":"\u003ca\u003e"}
+{
+  "0": "<a>"
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -6,6 +6,7 @@ package phperjson
 
 import (
 	"bytes"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -139,3 +140,27 @@ func simpleLetterEqualFold(s, t []byte) bool {
 	}
 	return true
 }
+
+// appendFoldedName appends a folded form of in to out,
+// such that the folded forms of x and y are equal if bytes.EqualFold(x, y) is true.
+// It is used as a key of the case-insensitive index of struct fields.
+func appendFoldedName(out, in []byte) []byte {
+	for i := 0; i < len(in); {
+		// Handle single-byte ASCII.
+		if c := in[i]; c < utf8.RuneSelf {
+			if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			out = append(out, c)
+			i++
+			continue
+		}
+		// Handle multi-byte Unicode.
+		r, n := utf8.DecodeRune(in[i:])
+		var buf [utf8.UTFMax]byte
+		m := utf8.EncodeRune(buf[:], unicode.ToUpper(unicode.ToLower(r)))
+		out = append(out, buf[:m]...)
+		i += n
+	}
+	return out
+}
@@ -51,6 +51,15 @@ func TestFold(t *testing.T) {
 	}
 }
 
+func TestAppendFoldedName(t *testing.T) {
+	for i, tt := range foldTests {
+		got := bytes.Equal(appendFoldedName(nil, []byte(tt.s)), appendFoldedName(nil, []byte(tt.t)))
+		if got != tt.want {
+			t.Errorf("%d. %q, %q = %v; want %v", i, tt.s, tt.t, got, tt.want)
+		}
+	}
+}
+
 func TestFoldAgainstUnicode(t *testing.T) {
 	const bufSize = 5
 	buf1 := make([]byte, 0, bufSize)
@@ -0,0 +1,251 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Juggling is a set of PHP flavored type conversions that a Decoder performs
+// when the type of the JSON value doesn't match the destination.
+// See http://php.net/manual/en/language.types.type-juggling.php for more detail.
+//
+// Int conversions apply to both signed and unsigned integer types.
+// Array conversions from scalar values wrap the value into slices, maps and structs
+// as an array with a single element with index zero.
+type Juggling uint32
+
+const (
+	// JuggleBoolToString converts true into "1" and false into "".
+	JuggleBoolToString Juggling = 1 << iota
+
+	// JuggleBoolToInt converts true into 1 and false into 0.
+	JuggleBoolToInt
+
+	// JuggleBoolToFloat converts true into 1.0 and false into 0.0.
+	JuggleBoolToFloat
+
+	// JuggleBoolToArray wraps booleans into slices, maps and structs.
+	JuggleBoolToArray
+
+	// JuggleNumberToBool converts zero into false and other numbers into true.
+	JuggleNumberToBool
+
+	// JuggleNumberToString converts numbers into their string representations.
+	JuggleNumberToString
+
+	// JuggleNumberToArray wraps numbers into slices, maps and structs.
+	JuggleNumberToArray
+
+	// JuggleStringToBool converts "" and "0" into false and other strings into true.
+	JuggleStringToBool
+
+	// JuggleStringToInt converts numeric strings into integers.
+	JuggleStringToInt
+
+	// JuggleStringToFloat converts numeric strings into floating point numbers.
+	JuggleStringToFloat
+
+	// JuggleStringToArray wraps strings into slices, maps and structs.
+	// Strings into []byte are decoded as base64-encoded strings regardless of this conversion.
+	JuggleStringToArray
+
+	// JuggleArrayToBool converts empty arrays into false and other arrays into true.
+	JuggleArrayToBool
+
+	// JuggleArrayToObject decodes arrays into maps and structs, using the indexes as the keys.
+	JuggleArrayToObject
+
+	// JuggleObjectToBool converts empty objects into false and other objects into true.
+	JuggleObjectToBool
+
+	// JuggleObjectToArray decodes objects into slices and arrays, using the keys as the indexes.
+	// See SetObjectToSlicePolicy for the keys that are not sequential.
+	// PHP encodes arrays into JSON objects with the JSON_FORCE_OBJECT option,
+	// or if the keys of the arrays are not sequential.
+	JuggleObjectToArray
+
+	// JuggleNone disables all type juggling.
+	JuggleNone Juggling = 0
+
+	// JuggleAll enables all type juggling. It is the default.
+	JuggleAll = JuggleObjectToArray<<1 - 1
+)
+
+// jugglingNames is the names of the conversions used in the juggle struct tags.
+var jugglingNames = map[string]Juggling{
+	"none":             JuggleNone,
+	"all":              JuggleAll,
+	"bool-to-string":   JuggleBoolToString,
+	"bool-to-int":      JuggleBoolToInt,
+	"bool-to-float":    JuggleBoolToFloat,
+	"bool-to-array":    JuggleBoolToArray,
+	"number-to-bool":   JuggleNumberToBool,
+	"number-to-string": JuggleNumberToString,
+	"number-to-array":  JuggleNumberToArray,
+	"string-to-bool":   JuggleStringToBool,
+	"string-to-int":    JuggleStringToInt,
+	"string-to-float":  JuggleStringToFloat,
+	"string-to-array":  JuggleStringToArray,
+	"array-to-bool":    JuggleArrayToBool,
+	"array-to-object":  JuggleArrayToObject,
+	"object-to-bool":   JuggleObjectToBool,
+	"object-to-array":  JuggleObjectToArray,
+}
+
+// parseJuggling parses the juggle struct tag, such as `juggle:"string-to-int,string-to-float"`.
+// It returns an error for unknown names.
+func parseJuggling(tag string) (Juggling, error) {
+	var j Juggling
+	for _, name := range strings.Split(tag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		v, ok := jugglingNames[name]
+		if !ok {
+			return JuggleNone, fmt.Errorf("unknown conversion %q", name)
+		}
+		j |= v
+	}
+	return j, nil
+}
+
+// SetJuggling sets the type conversions that the Decoder performs.
+// Conversions not in j cause the Decoder to return a *DecodeError wrapping *UnmarshalTypeError.
+//
+// The struct field tag "juggle" overrides it for the value of the field.
+// The tag is a comma-separated list of the conversion names,
+// such as "string-to-int" for JuggleStringToInt, "all" or "none".
+// Unknown names in the tag cause the Decoder to return an error for the struct type.
+//
+//	type User struct {
+//		ID   int    `json:"id" juggle:"string-to-int"`
+//		Name string `json:"name" juggle:"none"`
+//	}
+func (dec *Decoder) SetJuggling(j Juggling) {
+	dec.noJuggling = JuggleAll &^ j
+}
+
+// DisallowTypeJuggling causes the Decoder to return a *DecodeError wrapping *UnmarshalTypeError
+// when the kind of the JSON value doesn't match the destination.
+// Conversions between booleans, numbers, strings and arrays are disabled,
+// but JSON objects are still decoded into slices and arrays, and JSON arrays into maps and structs,
+// because PHP doesn't distinguish them.
+// It is same as SetJuggling(JuggleObjectToArray | JuggleArrayToObject).
+//
+// It doesn't change how JSON numbers are converted into integers,
+// so 1.5 is still truncated into 1, and 1e3 is decoded into 1000.
+// Use SetFloatToIntPolicy(FloatToIntError) together with it
+// for rejecting them in the same way as encoding/json.
+func (dec *Decoder) DisallowTypeJuggling() {
+	dec.SetJuggling(JuggleObjectToArray | JuggleArrayToObject)
+}
+
+// allowJuggling reports whether dec performs the conversion j.
+func (dec *Decoder) allowJuggling(j Juggling) bool {
+	return dec.noJuggling&j == 0
+}
+
+// literalJuggling returns the conversion for storing the JSON literal starting with c into v.
+// It returns JuggleNone if no conversions are needed.
+func literalJuggling(c byte, v reflect.Value) Juggling {
+	switch c {
+	case 'n':
+		return JuggleNone
+	case 't', 'f':
+		switch v.Kind() {
+		case reflect.String:
+			return JuggleBoolToString
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return JuggleBoolToInt
+		case reflect.Float32, reflect.Float64:
+			return JuggleBoolToFloat
+		case reflect.Slice, reflect.Map, reflect.Struct:
+			return JuggleBoolToArray
+		}
+	case '"':
+		switch v.Kind() {
+		case reflect.Bool:
+			return JuggleStringToBool
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return JuggleStringToInt
+		case reflect.Float32, reflect.Float64:
+			return JuggleStringToFloat
+		case reflect.Slice:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				// []byte is encoded as a base64-encoded string.
+				return JuggleNone
+			}
+			return JuggleStringToArray
+		case reflect.Map, reflect.Struct:
+			return JuggleStringToArray
+		}
+	default:
+		switch v.Kind() {
+		case reflect.Bool:
+			return JuggleNumberToBool
+		case reflect.String:
+			return JuggleNumberToString
+		case reflect.Slice, reflect.Map, reflect.Struct:
+			return JuggleNumberToArray
+		}
+	}
+	return JuggleNone
+}
+
+// A Coercion describes a type conversion that a Decoder performs.
+type Coercion struct {
+	Path       string       // the JSON path to the value, such as "items[3].price"
+	Conversion Juggling     // the conversion performed
+	Source     string       // the kind of the JSON value: "bool", "number", "string", "array" or "object"
+	Type       reflect.Type // the type of the Go value the JSON value is converted into
+	Value      RawMessage   // the original JSON value
+}
+
+// SetCoercionHook sets the function that is called
+// for every type conversion that the Decoder performs,
+// such as a string parsed into an int or an object decoded into a slice.
+// It is useful for finding the values that rely on type juggling.
+// Conversions that fail, such as "x" into an int, are not reported.
+// Numbers with fractional parts truncated into integers, such as 1.5 into 1, are not reported either,
+// because SetFloatToIntPolicy controls them instead of Juggling.
+//
+// c.Value refers to the input of the Decoder,
+// so the hook must copy it to retain it after returning.
+func (dec *Decoder) SetCoercionHook(f func(c Coercion)) {
+	dec.coercionHook = f
+}
+
+// coerce calls the coercion hook for the conversion j of the JSON value into the type t.
+func (dec *Decoder) coerce(j Juggling, value []byte, t reflect.Type) {
+	if dec.coercionHook == nil {
+		return
+	}
+	var source string
+	switch value[0] {
+	case '{':
+		source = "object"
+	case '[':
+		source = "array"
+	case 't', 'f':
+		source = "bool"
+	case '"':
+		source = "string"
+	default:
+		source = "number"
+	}
+	dec.coercionHook(Coercion{
+		Path:       dec.pathString(),
+		Conversion: j,
+		Source:     source,
+		Type:       t,
+		Value:      value,
+	})
+}
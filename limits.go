@@ -0,0 +1,182 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Limits is the limits of the JSON values that a Decoder decodes,
+// for decoding untrusted input safely.
+// Zero means no limit, except MaxSliceIndex.
+type Limits struct {
+	// MaxDepth is the max nesting depth of arrays and objects.
+	// PHP's json_decode defaults to 512.
+	MaxDepth int
+
+	// MaxSliceIndex is the max index of the slices decoded from JSON objects.
+	// The keys of the objects are interpreted as the indexes of the slices,
+	// so a small object such as {"999999999": 1} may allocate a huge slice.
+	// Zero means the default limit, which is the larger of 65535 and the size of the JSON value in bytes,
+	// so the size of the slices is proportional to the input.
+	// Negative means no limit, and only the indexes that the Go runtime can't allocate are rejected.
+	MaxSliceIndex int
+
+	// MaxElements is the max total number of array elements and object members in a JSON value.
+	MaxElements int
+
+	// MaxBytes is the max size of a JSON value in bytes.
+	// The Decoder stops reading the input stream when the value being read exceeds it,
+	// so the memory for buffering the value is bounded.
+	MaxBytes int
+}
+
+// defaultMaxSliceIndex is the lower bound of the default MaxSliceIndex.
+const defaultMaxSliceIndex = 1<<16 - 1
+
+// maxSliceIndex returns the effective MaxSliceIndex for dec.data.
+// It returns a negative value if there is no limit.
+func (dec *Decoder) maxSliceIndex() int {
+	if max := dec.limits.MaxSliceIndex; max != 0 {
+		return max
+	}
+	if len(dec.data) > defaultMaxSliceIndex {
+		return len(dec.data)
+	}
+	return defaultMaxSliceIndex
+}
+
+// SetLimits sets the limits of the JSON values that the Decoder decodes.
+// If a JSON value exceeds one of them, the Decoder returns a *DecodeError wrapping *LimitError.
+func (dec *Decoder) SetLimits(l Limits) {
+	dec.limits = l
+}
+
+// A LimitError describes a JSON value exceeding one of the limits of a Decoder.
+type LimitError struct {
+	Limit string // the limit exceeded: "depth", "slice index", "elements" or "bytes"
+	Max   int    // the value of the limit
+}
+
+func (e *LimitError) Error() string {
+	return "phperjson: exceeded max " + e.Limit + " " + strconv.Itoa(e.Max)
+}
+
+// checkLimits checks that dec.data doesn't exceed dec.limits, except MaxSliceIndex.
+func (dec *Decoder) checkLimits() error {
+	l := dec.limits
+	if l.MaxBytes > 0 && len(dec.data) > l.MaxBytes {
+		return dec.withErrorContext(&LimitError{Limit: "bytes", Max: l.MaxBytes}, 0)
+	}
+	if l.MaxDepth <= 0 && l.MaxElements <= 0 {
+		return nil
+	}
+
+	depth := 0
+	elements := 0
+	data := dec.data
+	for i := 0; i < len(data); i++ {
+		element := false
+		switch data[i] {
+		case '"':
+			// skip the string
+			for i++; data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case '{', '[':
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return dec.withErrorContext(&LimitError{Limit: "depth", Max: l.MaxDepth}, i)
+			}
+			// a non-empty array or object has its first element here.
+			j := i + 1
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\r' || data[j] == '\n') {
+				j++
+			}
+			element = data[j] != '}' && data[j] != ']'
+		case '}', ']':
+			depth--
+		case ',':
+			element = true
+		}
+		if element {
+			elements++
+			if l.MaxElements > 0 && elements > l.MaxElements {
+				return dec.withErrorContext(&LimitError{Limit: "elements", Max: l.MaxElements}, i)
+			}
+		}
+	}
+	return nil
+}
+
+// errBytesLimit is the error that limitReader returns when it reaches the limit.
+var errBytesLimit = errors.New("phperjson: exceeded max bytes")
+
+// limitReader reads from r at most n bytes, if n is not negative.
+// Unlike io.LimitedReader, it reports errBytesLimit instead of io.EOF at the limit,
+// so that json.Decoder doesn't mistake it for the end of the input.
+// The leading white spaces don't count toward n up to spaces bytes.
+type limitReader struct {
+	r       io.Reader
+	n       int64
+	spaces  int64
+	leading bool // no bytes other than white spaces have been read since the limit was set
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.n < 0 {
+		return r.r.Read(p)
+	}
+	if r.n == 0 {
+		return 0, errBytesLimit
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	for i := 0; r.leading && i < n; i++ {
+		switch p[i] {
+		case ' ', '\t', '\r', '\n':
+			if r.spaces > 0 {
+				r.spaces--
+				r.n++
+			}
+		default:
+			r.leading = false
+		}
+	}
+	return n, err
+}
+
+// read reads the next JSON value from the input stream into dec.buf.
+// With MaxBytes, json.Decoder may read only MaxBytes+1 bytes more than it has buffered,
+// which is enough for the values within the limit, including the byte that terminates a number,
+// and MaxBytes bytes more for the white spaces before the value.
+// The values that start in the buffer may be larger than MaxBytes, and checkLimits rejects them.
+func (dec *Decoder) read() error {
+	if max := dec.limits.MaxBytes; max > 0 {
+		dec.limit.n = int64(max) + 1
+		dec.limit.spaces = int64(max)
+		dec.limit.leading = true
+		defer func() { dec.limit.n = -1 }()
+	}
+	err := dec.dec.Decode(&dec.buf)
+	if err == errBytesLimit {
+		// json.Decoder doesn't consume the value, so its input offset is the start of the value.
+		dec.data = nil
+		dec.newlines = nil
+		if dec.lines != nil {
+			dec.base = inputOffset(dec.dec)
+			dec.line, dec.lineStart = dec.lines.lineAt(dec.base)
+		}
+		return dec.withErrorContext(&LimitError{Limit: "bytes", Max: dec.limits.MaxBytes}, 0)
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// NullLike is a set of JSON values that a Decoder treats as null
+// for pointers, slices, maps, structs and types implementing encoding.TextUnmarshaler.
+//
+// PHP functions return false on failure, and PHP forms send "" for empty inputs,
+// so PHP-encoded JSON may have them where an object or a date is expected.
+type NullLike uint8
+
+const (
+	// NullLikeFalse treats false as null.
+	NullLikeFalse NullLike = 1 << iota
+
+	// NullLikeEmptyString treats "" as null.
+	// []byte is decoded from "" as an empty base64-encoded string regardless of this.
+	NullLikeEmptyString
+
+	// NullLikeNone treats only null as null. It is the default.
+	NullLikeNone NullLike = 0
+
+	// NullLikeAll treats false and "" as null.
+	NullLikeAll = NullLikeFalse | NullLikeEmptyString
+)
+
+// nullLikeNames is the names of the values used in the null struct tags.
+var nullLikeNames = map[string]NullLike{
+	"none":         NullLikeNone,
+	"all":          NullLikeAll,
+	"false":        NullLikeFalse,
+	"empty-string": NullLikeEmptyString,
+}
+
+// parseNullLike parses the null struct tag, such as `null:"false,empty-string"`.
+// It returns an error for unknown names.
+func parseNullLike(tag string) (NullLike, error) {
+	var n NullLike
+	for _, name := range strings.Split(tag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		v, ok := nullLikeNames[name]
+		if !ok {
+			return NullLikeNone, fmt.Errorf("unknown null-like value %q", name)
+		}
+		n |= v
+	}
+	return n, nil
+}
+
+// SetNullLike sets the JSON values that the Decoder treats as null
+// for pointers, slices, maps, structs and types implementing encoding.TextUnmarshaler.
+// For example, with NullLikeFalse, false is decoded into a pointer as nil instead of a type error,
+// and into a slice as nil instead of []bool{false}.
+// Other types, such as bool, string and interface{}, receive the values as they are.
+//
+// The struct field tag "null" overrides it for the value of the field.
+// The tag is a comma-separated list of "false", "empty-string", "all" or "none".
+// Unknown names in the tag cause the Decoder to return an error for the struct type.
+//
+//	type Session struct {
+//		User      *User     `json:"user" null:"false"`
+//		ExpiresAt time.Time `json:"expires_at" null:"empty-string"`
+//	}
+func (dec *Decoder) SetNullLike(n NullLike) {
+	dec.nullLike = n
+}
+
+// nullLiteral is the JSON literal that the values treated as null are replaced with.
+var nullLiteral = []byte("null")
+
+// isNullLike reports whether dec treats the JSON literal item as null for v.
+func (dec *Decoder) isNullLike(item []byte, v reflect.Value) bool {
+	switch string(item) {
+	case "false":
+		if dec.nullLike&NullLikeFalse == 0 {
+			return false
+		}
+	case `""`:
+		if dec.nullLike&NullLikeEmptyString == 0 {
+			return false
+		}
+	default:
+		return false
+	}
+
+	t := v.Type()
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Struct:
+		return true
+	case reflect.Slice:
+		// "" is a valid base64-encoded string for []byte.
+		return item[0] != '"' || t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNullLike(t *testing.T) {
+	tests := []struct {
+		in  string
+		out NullLike
+		err string
+	}{
+		{in: "", out: NullLikeNone},
+		{in: "none", out: NullLikeNone},
+		{in: "all", out: NullLikeAll},
+		{in: "false", out: NullLikeFalse},
+		{in: "false, empty-string", out: NullLikeAll},
+		{in: "empty-string,unknown", err: `unknown null-like value "unknown"`},
+		{in: "flase", err: `unknown null-like value "flase"`},
+	}
+	for _, tt := range tests {
+		got, err := parseNullLike(tt.in)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseNullLike(%q): got error %v, want %s", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseNullLike(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.out {
+			t.Errorf("parseNullLike(%q): got %d, want %d", tt.in, got, tt.out)
+		}
+	}
+
+	// the misspelled tags are rejected instead of falling back to NullLikeNone.
+	type typo struct {
+		User *struct{} `null:"flase"`
+	}
+	var v typo
+	err := Unmarshal([]byte(`{"User":false}`), &v)
+	want := `phperjson: invalid null tag of phperjson.typo.User: unknown null-like value "flase"`
+	if err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestSetNullLike(t *testing.T) {
+	type user struct {
+		Name string
+	}
+	type target struct {
+		Ptr    *user
+		Slice  []int
+		Map    map[string]int
+		Struct user
+		Time   time.Time
+		PTime  *time.Time
+		Bytes  []byte
+		Bool   bool
+		String string
+		Iface  interface{}
+	}
+	now := time.Unix(1, 0)
+	fill := func() target {
+		return target{
+			Ptr:    &user{Name: "a"},
+			Slice:  []int{1},
+			Map:    map[string]int{"a": 1},
+			Struct: user{Name: "a"},
+			Time:   now,
+			PTime:  &now,
+			Bytes:  []byte("a"),
+			Bool:   true,
+			String: "a",
+			Iface:  1,
+		}
+	}
+
+	tests := []struct {
+		in         string
+		nullLike   NullLike
+		zeroOnNull bool
+		out        target
+	}{
+		{
+			in:       `{"Ptr":false,"Slice":false,"Map":false,"Struct":false,"PTime":false,"Bool":false,"Iface":false}`,
+			nullLike: NullLikeFalse,
+			out:      target{Struct: user{Name: "a"}, Time: now, Bytes: []byte("a"), String: "a", Iface: false},
+		},
+		{
+			in:       `{"Ptr":"","Slice":"","Map":"","Struct":"","Time":"","PTime":"","Bytes":"","String":"","Iface":""}`,
+			nullLike: NullLikeEmptyString,
+			out:      target{Struct: user{Name: "a"}, Time: now, Bytes: []byte{}, Bool: true, Iface: ""},
+		},
+		{
+			in:         `{"Struct":false,"Time":""}`,
+			nullLike:   NullLikeAll,
+			zeroOnNull: true,
+			out: func() target {
+				v := fill()
+				v.Struct = user{}
+				return v
+			}(),
+		},
+	}
+	for i, tt := range tests {
+		got := fill()
+		dec := NewDecoder(strings.NewReader(tt.in))
+		dec.SetNullLike(tt.nullLike)
+		if tt.zeroOnNull {
+			dec.ZeroOnNull()
+		}
+		if err := dec.Decode(&got); err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("#%d: got %#v, want %#v", i, got, tt.out)
+		}
+	}
+
+	// "" is not null without NullLikeEmptyString.
+	var v target
+	dec := NewDecoder(strings.NewReader(`{"PTime":""}`))
+	dec.SetNullLike(NullLikeFalse)
+	if err := dec.Decode(&v); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestNullTag(t *testing.T) {
+	type user struct {
+		Name string
+	}
+	type target struct {
+		A *user `null:"false"`
+		B *user
+		C *user `null:"none"`
+	}
+	in := `{"A":false,"B":false,"C":false}`
+
+	var v target
+	if err := Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != nil || v.B == nil || v.C == nil {
+		t.Errorf("got %#v", v)
+	}
+
+	// the tag overrides the setting of the decoder.
+	v = target{}
+	dec := NewDecoder(strings.NewReader(in))
+	dec.SetNullLike(NullLikeAll)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != nil || v.B != nil || v.C == nil {
+		t.Errorf("got %#v", v)
+	}
+
+	// the tag also applies to the scalar values wrapped into the struct as index zero.
+	type wrapped struct {
+		A *user `json:"0" null:"false"`
+	}
+	var w wrapped
+	if err := Unmarshal([]byte(`false`), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.A != nil {
+		t.Errorf("got %#v", w)
+	}
+}
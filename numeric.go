@@ -0,0 +1,130 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import "strconv"
+
+// PHP flavored numeric strings
+// https://www.php.net/manual/en/language.types.numeric-strings.php
+//
+//   WHITESPACES      \s*
+//   LNUM             [0-9]+
+//   DNUM             ([0-9]*[\.]{LNUM}) | ({LNUM}[\.][0-9]*)
+//   EXPONENT_DNUM    (({LNUM} | {DNUM}) [eE][+-]? {LNUM})
+//   INT_NUM_STRING   {WHITESPACES} [+-]? {LNUM} {WHITESPACES}
+//   FLOAT_NUM_STRING {WHITESPACES} [+-]? ({DNUM} | {EXPONENT_DNUM}) {WHITESPACES}
+//   NUM_STRING       ({INT_NUM_STRING} | {FLOAT_NUM_STRING})
+
+// isPHPSpace reports whether c is a white space in PHP numeric strings.
+func isPHPSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// scanNumericString scans the leading numeric part of s.
+// It returns the number without leading white spaces, and the rest of s after the number.
+// ok is false if s doesn't start with a number.
+func scanNumericString(s []byte) (num, rest []byte, ok bool) {
+	i := 0
+	for i < len(s) && isPHPSpace(s[i]) {
+		i++
+	}
+	start := i
+
+	// sign
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+
+	// mantissa
+	digits := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		j := i + 1
+		frac := 0
+		for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+			j++
+			frac++
+		}
+		if digits+frac > 0 {
+			i = j
+			digits += frac
+		}
+	}
+	if digits == 0 {
+		return nil, s, false
+	}
+
+	// exponent
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		k := j
+		for k < len(s) && '0' <= s[k] && s[k] <= '9' {
+			k++
+		}
+		if k > j {
+			i = k
+		}
+	}
+	return s[start:i], s[i:], true
+}
+
+// numericString returns the number in the PHP numeric string s.
+// If dec.allowLeadingNumeric is true, it also accepts leading-numeric strings, such as "123abc".
+func (dec *Decoder) numericString(s []byte) ([]byte, bool) {
+	num, rest, ok := scanNumericString(s)
+	if dec.phpVersion != PHP7 {
+		// PHP 8 allows trailing white spaces.
+		// https://wiki.php.net/rfc/saner-string-to-number
+		for len(rest) > 0 && isPHPSpace(rest[0]) {
+			rest = rest[1:]
+		}
+	}
+	if !ok {
+		if dec.allowLeadingNumeric && dec.phpVersion == PHP7 {
+			// PHP 7 converts non-numeric strings into zero with a warning.
+			return []byte("0"), true
+		}
+		return nil, false
+	}
+	if len(rest) != 0 && !dec.allowLeadingNumeric {
+		return nil, false
+	}
+	return num, true
+}
+
+// maxInt is the max value of int.
+const maxInt = int(^uint(0) >> 1)
+
+// phpIntKey reports whether key is a decimal integer
+// that PHP casts into an integer array key, and returns the integer.
+// "8" is cast into the integer 8, but "08", "+8" and "8.0" are not.
+// See http://php.net/manual/en/language.types.array.php
+func phpIntKey(key []byte) (int64, bool) {
+	s := key
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 || (s[0] == '0' && len(key) > 1) {
+		// empty, leading zeros, or "-0"
+		return 0, false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(string(key), 10, 64)
+	if err != nil {
+		// out of the range of integer keys
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"reflect"
+)
+
+// The optional types record whether the JSON value is present, null or a value,
+// which is useful for PATCH-style APIs.
+// A missing key leaves Present false, null sets Present and Null,
+// and other values set Present and Value with the usual type juggling of the Decoder.
+//
+// They are encoded into null if they are not present or null, otherwise into Value.
+// With the omitempty option, the fields that are not present are omitted.
+//
+//	type UserPatch struct {
+//		Name phperjson.OptionalString `json:"name,omitempty"`
+//		Age  phperjson.OptionalInt64  `json:"age,omitempty"`
+//	}
+//
+// All of them have the fields Present, Null and Value in this order.
+
+// OptionalString is a string that may be missing or null.
+type OptionalString struct {
+	Present bool // the value is present in JSON
+	Null    bool // the value is null
+	Value   string
+}
+
+// OptionalInt64 is an int64 that may be missing or null.
+type OptionalInt64 struct {
+	Present bool // the value is present in JSON
+	Null    bool // the value is null
+	Value   int64
+}
+
+// OptionalFloat64 is a float64 that may be missing or null.
+type OptionalFloat64 struct {
+	Present bool // the value is present in JSON
+	Null    bool // the value is null
+	Value   float64
+}
+
+// OptionalBool is a bool that may be missing or null.
+// It distinguishes false from missing and null.
+type OptionalBool struct {
+	Present bool // the value is present in JSON
+	Null    bool // the value is null
+	Value   bool
+}
+
+// OptionalValue is a value of any type that may be missing or null.
+// The JSON value is decoded into Value in the same way as interface{}.
+// If Value holds a non-nil pointer, the JSON value is decoded into the value it points to,
+// so it can be used for any type.
+// null leaves such a pointer and the value it points to as they are, and only sets Null.
+//
+//	v := phperjson.OptionalValue{Value: &User{}}
+type OptionalValue struct {
+	Present bool // the value is present in JSON
+	Null    bool // the value is null
+	Value   interface{}
+}
+
+// optionalTypes is the set of the optional types.
+var optionalTypes = map[reflect.Type]bool{
+	reflect.TypeOf(OptionalString{}):  true,
+	reflect.TypeOf(OptionalInt64{}):   true,
+	reflect.TypeOf(OptionalFloat64{}): true,
+	reflect.TypeOf(OptionalBool{}):    true,
+	reflect.TypeOf(OptionalValue{}):   true,
+}
+
+// MarshalJSON implements Marshaler.
+func (o OptionalString) MarshalJSON() ([]byte, error) { return Marshal(o) }
+
+// UnmarshalJSON implements Unmarshaler.
+func (o *OptionalString) UnmarshalJSON(data []byte) error { return Unmarshal(data, o) }
+
+// MarshalJSON implements Marshaler.
+func (o OptionalInt64) MarshalJSON() ([]byte, error) { return Marshal(o) }
+
+// UnmarshalJSON implements Unmarshaler.
+func (o *OptionalInt64) UnmarshalJSON(data []byte) error { return Unmarshal(data, o) }
+
+// MarshalJSON implements Marshaler.
+func (o OptionalFloat64) MarshalJSON() ([]byte, error) { return Marshal(o) }
+
+// UnmarshalJSON implements Unmarshaler.
+func (o *OptionalFloat64) UnmarshalJSON(data []byte) error { return Unmarshal(data, o) }
+
+// MarshalJSON implements Marshaler.
+func (o OptionalBool) MarshalJSON() ([]byte, error) { return Marshal(o) }
+
+// UnmarshalJSON implements Unmarshaler.
+func (o *OptionalBool) UnmarshalJSON(data []byte) error { return Unmarshal(data, o) }
+
+// MarshalJSON implements Marshaler.
+func (o OptionalValue) MarshalJSON() ([]byte, error) { return Marshal(o) }
+
+// UnmarshalJSON implements Unmarshaler.
+func (o *OptionalValue) UnmarshalJSON(data []byte) error { return Unmarshal(data, o) }
+
+// optional decodes the next JSON value into the optional value v, such as OptionalString.
+// The optional types implement Unmarshaler, but they are decoded natively
+// so that the settings of the Decoder apply to Value.
+func (dec *Decoder) optional(v reflect.Value) error {
+	dec.skipSpaces()
+	v.Field(0).SetBool(true) // Present
+	value := v.Field(2)
+	if dec.data[dec.off] == 'n' {
+		dec.off += len("null")
+		v.Field(1).SetBool(true) // Null
+		if value.Kind() == reflect.Interface && !value.IsNil() &&
+			value.Elem().Kind() == reflect.Ptr && !value.Elem().IsNil() {
+			// keep the pointer that the caller provides in OptionalValue.
+			return nil
+		}
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+	v.Field(1).SetBool(false) // Null
+	return dec.value(value)
+}
+
+// optionalPtr decodes the next JSON value into the pointer v to an optional value.
+// null sets v to nil in the same way as other pointers, if v is settable.
+func (dec *Decoder) optionalPtr(v reflect.Value) error {
+	dec.skipSpaces()
+	if dec.data[dec.off] == 'n' && v.CanSet() {
+		dec.off += len("null")
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return dec.optional(v.Elem())
+}
+
+// optionalEncoder encodes the optional value v, such as OptionalString.
+// The optional types implement Marshaler, but they are encoded natively
+// so that the flags of the encoder apply to Value.
+func optionalEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if !v.Field(0).Bool() || v.Field(1).Bool() {
+		e.WriteString("null")
+		return
+	}
+	e.reflectValue(v.Field(2), opts)
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type optionalPatch struct {
+	Name  OptionalString  `json:"name,omitempty"`
+	Age   OptionalInt64   `json:"age,omitempty"`
+	Score OptionalFloat64 `json:"score,omitempty"`
+	Admin OptionalBool    `json:"admin,omitempty"`
+	Extra OptionalValue   `json:"extra,omitempty"`
+	Ptr   *OptionalInt64  `json:"ptr,omitempty"`
+}
+
+func TestOptionalUnmarshal(t *testing.T) {
+	tests := []struct {
+		in  string
+		out optionalPatch
+	}{
+		{
+			in:  `{}`,
+			out: optionalPatch{},
+		},
+		{
+			in: `{"name":null,"age":null,"score":null,"admin":null,"extra":null,"ptr":null}`,
+			out: optionalPatch{
+				Name:  OptionalString{Present: true, Null: true},
+				Age:   OptionalInt64{Present: true, Null: true},
+				Score: OptionalFloat64{Present: true, Null: true},
+				Admin: OptionalBool{Present: true, Null: true},
+				Extra: OptionalValue{Present: true, Null: true},
+			},
+		},
+		{
+			in: `{"name":"foo","age":"42","score":1,"admin":false,"extra":[1],"ptr":3}`,
+			out: optionalPatch{
+				Name:  OptionalString{Present: true, Value: "foo"},
+				Age:   OptionalInt64{Present: true, Value: 42},
+				Score: OptionalFloat64{Present: true, Value: 1},
+				Admin: OptionalBool{Present: true},
+				Extra: OptionalValue{Present: true, Value: []interface{}{1.0}},
+				Ptr:   &OptionalInt64{Present: true, Value: 3},
+			},
+		},
+		{
+			// type juggling
+			in: `{"name":12,"age":1.0,"score":"1.5","admin":"1"}`,
+			out: optionalPatch{
+				Name:  OptionalString{Present: true, Value: "12"},
+				Age:   OptionalInt64{Present: true, Value: 1},
+				Score: OptionalFloat64{Present: true, Value: 1.5},
+				Admin: OptionalBool{Present: true, Value: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		var got optionalPatch
+		if err := Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("Unmarshal(%s): got %#v, want %#v", tt.in, got, tt.out)
+		}
+	}
+
+	// the settings of the decoder apply to Value.
+	var v optionalPatch
+	dec := NewDecoder(strings.NewReader(`{"age":"42"}`))
+	dec.DisallowTypeJuggling()
+	err := dec.Decode(&v)
+	if de, ok := err.(*DecodeError); !ok || de.Path != "age" {
+		t.Errorf("got %#v, want *DecodeError", err)
+	}
+
+	// null resets the decoded value.
+	v = optionalPatch{Name: OptionalString{Present: true, Value: "foo"}}
+	if err := Unmarshal([]byte(`{"name":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if want := (OptionalString{Present: true, Null: true}); v.Name != want {
+		t.Errorf("got %#v, want %#v", v.Name, want)
+	}
+
+	// OptionalValue decodes into the value that Value points to.
+	type user struct {
+		ID int
+	}
+	o := OptionalValue{Value: &user{}}
+	if err := Unmarshal([]byte(`{"ID":"7"}`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if u, ok := o.Value.(*user); !ok || !o.Present || u.ID != 7 {
+		t.Errorf("got %#v", o)
+	}
+
+	// null keeps the pointer in Value.
+	u := &user{ID: 3}
+	o = OptionalValue{Value: u}
+	if err := Unmarshal([]byte(`null`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Value != u || u.ID != 3 || !o.Present || !o.Null {
+		t.Errorf("got %#v", o)
+	}
+
+	// null clears the other values in Value.
+	o = OptionalValue{Value: "foo"}
+	if err := Unmarshal([]byte(`null`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o != (OptionalValue{Present: true, Null: true}) {
+		t.Errorf("got %#v", o)
+	}
+
+	// encoding/json uses UnmarshalJSON.
+	var s struct{ A, B, C OptionalInt64 }
+	if err := json.Unmarshal([]byte(`{"A":null,"B":"5"}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.A != (OptionalInt64{Present: true, Null: true}) || s.B != (OptionalInt64{Present: true, Value: 5}) || s.C.Present {
+		t.Errorf("got %#v", s)
+	}
+}
+
+func TestOptionalMarshal(t *testing.T) {
+	tests := []struct {
+		in    interface{}
+		flags EncodeFlag
+		out   string
+	}{
+		{in: optionalPatch{}, out: `{}`},
+		{
+			in: optionalPatch{
+				Name:  OptionalString{Present: true, Null: true},
+				Age:   OptionalInt64{Present: true, Value: 0},
+				Admin: OptionalBool{Present: true, Value: false},
+				Extra: OptionalValue{Present: true},
+				Ptr:   &OptionalInt64{Present: true, Null: true},
+			},
+			out: `{"name":null,"age":0,"admin":false,"extra":null,"ptr":null}`,
+		},
+		{in: struct{ A OptionalString }{}, out: `{"A":null}`},
+		{in: OptionalValue{Present: true, Value: []int{1}}, flags: EncodeForceObject, out: `{"0":1}`},
+		{in: &OptionalString{Present: true, Value: "1"}, flags: EncodeNumericCheck, out: `1`},
+		{in: struct {
+			A OptionalInt64 `json:",numericstring"`
+		}{A: OptionalInt64{Present: true, Value: 1}}, out: `{"A":"1"}`},
+	}
+	for _, tt := range tests {
+		got, err := MarshalFlags(tt.in, tt.flags)
+		if err != nil {
+			t.Errorf("MarshalFlags(%#v): %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.out {
+			t.Errorf("MarshalFlags(%#v): got %s, want %s", tt.in, got, tt.out)
+		}
+	}
+
+	// encoding/json uses MarshalJSON.
+	got, err := json.Marshal(struct{ A, B OptionalFloat64 }{A: OptionalFloat64{Present: true, Value: 1.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"A":1.5,"B":null}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
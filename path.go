@@ -0,0 +1,72 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import "strconv"
+
+// pathElem is an element of the JSON path to the value being decoded.
+type pathElem struct {
+	key   []byte // the key of the object, if index < 0
+	index int    // the index of the array
+}
+
+// pushKey appends the object key to the path.
+func (dec *Decoder) pushKey(key []byte) {
+	dec.path = append(dec.path, pathElem{key: key, index: -1})
+}
+
+// pushIndex appends the array index to the path.
+func (dec *Decoder) pushIndex(i int) {
+	dec.path = append(dec.path, pathElem{index: i})
+}
+
+// popPath removes the last element of the path.
+func (dec *Decoder) popPath() {
+	dec.path = dec.path[:len(dec.path)-1]
+}
+
+// pathString returns the JSON path to the value being decoded, such as `items[3].price`.
+// The keys that are not identifiers are quoted, such as `items["0"]`.
+func (dec *Decoder) pathString() string {
+	var b []byte
+	for _, e := range dec.path {
+		switch {
+		case e.index >= 0:
+			b = append(b, '[')
+			b = strconv.AppendInt(b, int64(e.index), 10)
+			b = append(b, ']')
+		case isIdentifier(e.key):
+			if len(b) > 0 {
+				b = append(b, '.')
+			}
+			b = append(b, e.key...)
+		default:
+			b = append(b, '[')
+			b = strconv.AppendQuote(b, string(e.key))
+			b = append(b, ']')
+		}
+	}
+	return string(b)
+}
+
+// isIdentifier reports whether key can be written in the JSON path without quotes.
+func isIdentifier(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_' || c == '$':
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9':
+			if i == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The following functions format JSON values in the same way as PHP's json_encode.
+// https://github.com/php/php-src/blob/master/ext/json/json_encoder.c
+
+// phpString writes s as a JSON string in the same way as php_json_escape_string.
+// Invalid UTF-8 bytes are dropped or substituted following the flags of e.
+func (e *encodeState) phpString(s string) {
+	flags := e.flags
+	e.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			i++
+			switch {
+			case c == '"':
+				if flags&EncodeHexQuot != 0 {
+					e.WriteString(`\u0022`)
+				} else {
+					e.WriteString(`\"`)
+				}
+			case c == '\\':
+				e.WriteString(`\\`)
+			case c == '/':
+				if flags&EncodeUnescapedSlashes != 0 {
+					e.WriteByte('/')
+				} else {
+					e.WriteString(`\/`)
+				}
+			case c == '\b':
+				e.WriteString(`\b`)
+			case c == '\f':
+				e.WriteString(`\f`)
+			case c == '\n':
+				e.WriteString(`\n`)
+			case c == '\r':
+				e.WriteString(`\r`)
+			case c == '\t':
+				e.WriteString(`\t`)
+			case c == '<' && flags&EncodeHexTag != 0:
+				e.WriteString(`\u003C`)
+			case c == '>' && flags&EncodeHexTag != 0:
+				e.WriteString(`\u003E`)
+			case c == '&' && flags&EncodeHexAmp != 0:
+				e.WriteString(`\u0026`)
+			case c == '\'' && flags&EncodeHexApos != 0:
+				e.WriteString(`\u0027`)
+			case c < 0x20:
+				e.WriteString(`\u00`)
+				e.WriteByte(hex[c>>4])
+				e.WriteByte(hex[c&0xF])
+			default:
+				e.WriteByte(c)
+			}
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			i++
+			if flags&EncodeInvalidUTF8Substitute == 0 {
+				// JSON_INVALID_UTF8_IGNORE
+				continue
+			}
+			// JSON_INVALID_UTF8_SUBSTITUTE
+			if flags&EncodeUnescapedUnicode != 0 {
+				e.WriteRune(utf8.RuneError)
+			} else {
+				e.WriteString(`\ufffd`)
+			}
+			continue
+		}
+		if flags&EncodeUnescapedUnicode != 0 &&
+			(flags&EncodeUnescapedLineTerminators != 0 || r != '\u2028' && r != '\u2029') {
+			e.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+		i += size
+
+		// escape as UTF-16
+		if r >= 0x10000 {
+			r -= 0x10000
+			e.phpUnicode(0xD800 | (r >> 10))
+			r = 0xDC00 | (r & 0x3FF)
+		}
+		e.phpUnicode(r)
+	}
+	e.WriteByte('"')
+}
+
+// phpUnicode writes the UTF-16 code unit r as \uXXXX.
+func (e *encodeState) phpUnicode(r rune) {
+	e.WriteString(`\u`)
+	e.WriteByte(hex[(r>>12)&0xF])
+	e.WriteByte(hex[(r>>8)&0xF])
+	e.WriteByte(hex[(r>>4)&0xF])
+	e.WriteByte(hex[r&0xF])
+}
+
+// phpFloat writes the floating point number f of the bit size bits
+// in the same way as php_json_encode_double.
+func (e *encodeState) phpFloat(f float64, bits int) {
+	precision := -1 // serialize_precision
+	if e.flags&EncodeLegacyPrecision != 0 {
+		precision = 17
+	}
+	var scratch [64]byte
+	b := appendPHPFloat(scratch[:0], f, precision, bits)
+	if e.flags&EncodePreserveZeroFraction != 0 && bytes.IndexByte(b, '.') < 0 {
+		b = append(b, '.', '0')
+	}
+	e.Write(b)
+}
+
+// appendPHPFloat appends f formatted by php_gcvt with the precision.
+// If precision is -1, it uses the shortest representation that round-trips,
+// in the same way as zend_dtoa mode 0.
+func appendPHPFloat(b []byte, f float64, precision, bits int) []byte {
+	var s string
+	ndigit := precision
+	if precision < 0 {
+		s = strconv.FormatFloat(f, 'e', -1, bits)
+		ndigit = 17
+	} else {
+		s = strconv.FormatFloat(f, 'e', precision-1, 64)
+	}
+
+	// parse the digits and the exponent of "-d.ddde+xx"
+	if s[0] == '-' {
+		b = append(b, '-')
+		s = s[1:]
+	}
+	var digits []byte
+	i := 0
+	for ; s[i] != 'e'; i++ {
+		if s[i] != '.' {
+			digits = append(digits, s[i])
+		}
+	}
+	exp, _ := strconv.Atoi(s[i+1:])
+	for len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+	}
+	decpt := exp + 1 // the position of the decimal point in digits
+
+	switch {
+	case decpt < -3 || decpt > ndigit:
+		// exponential format (e.g. 1.0e+00)
+		b = append(b, digits[0], '.')
+		if len(digits) == 1 {
+			b = append(b, '0')
+		} else {
+			b = append(b, digits[1:]...)
+		}
+		b = append(b, 'e')
+		if exp < 0 {
+			b = append(b, '-')
+			exp = -exp
+		} else {
+			b = append(b, '+')
+		}
+		b = strconv.AppendInt(b, int64(exp), 10)
+	case decpt <= 0:
+		// standard format 0.
+		b = append(b, '0', '.')
+		for ; decpt < 0; decpt++ {
+			b = append(b, '0')
+		}
+		b = append(b, digits...)
+	default:
+		// standard format
+		for i := 0; i < decpt; i++ {
+			if i < len(digits) {
+				b = append(b, digits[i])
+			} else {
+				b = append(b, '0')
+			}
+		}
+		if decpt < len(digits) {
+			b = append(b, '.')
+			b = append(b, digits[decpt:]...)
+		}
+	}
+	return b
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// lineReader records the offsets of the newlines read from r,
+// for finding the line numbers of the values in the input stream.
+type lineReader struct {
+	r         io.Reader
+	off       int64   // the input offset of the next read
+	line      int     // the number of newlines before lineStart
+	lineStart int64   // the input offset of the start of the current line
+	newlines  []int64 // the input offsets of the newlines after lineStart
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := 0; i < n; {
+		j := bytes.IndexByte(p[i:n], '\n')
+		if j < 0 {
+			break
+		}
+		r.newlines = append(r.newlines, r.off+int64(i+j))
+		i += j + 1
+	}
+	r.off += int64(n)
+	return n, err
+}
+
+// lineAt returns the number of lines before the input offset off,
+// and the input offset of the line containing off.
+// off must not decrease between calls.
+func (r *lineReader) lineAt(off int64) (int, int64) {
+	i := 0
+	for i < len(r.newlines) && r.newlines[i] < off {
+		r.lineStart = r.newlines[i] + 1
+		i++
+	}
+	r.line += i
+	r.newlines = r.newlines[:copy(r.newlines, r.newlines[i:])]
+	return r.line, r.lineStart
+}
+
+// position returns the input offset, the line number and the column number of dec.data[off].
+// If the Decoder can't know the offset of dec.data in the input stream,
+// they are relative to the start of dec.data.
+func (dec *Decoder) position(off int) (offset int64, line, column int) {
+	offset = dec.base + int64(off)
+	if dec.newlines == nil {
+		// index the newlines at the first error in dec.data,
+		// so that the following errors don't scan dec.data again.
+		dec.newlines = []int{}
+		for i := 0; ; {
+			j := bytes.IndexByte(dec.data[i:], '\n')
+			if j < 0 {
+				break
+			}
+			dec.newlines = append(dec.newlines, i+j)
+			i += j + 1
+		}
+	}
+	line, lineStart := dec.line, dec.lineStart
+	if n := sort.SearchInts(dec.newlines, off); n > 0 {
+		line += n
+		lineStart = dec.base + int64(dec.newlines[n-1]) + 1
+	}
+	return offset, line + 1, int(offset-lineStart) + 1
+}
@@ -0,0 +1,19 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.14
+// +build !go1.14
+
+package phperjson
+
+import "encoding/json"
+
+// hasInputOffset reports whether json.Decoder has the InputOffset method.
+// json.Decoder.InputOffset is available from Go 1.14,
+// so the positions in the errors are relative to the start of the value.
+const hasInputOffset = false
+
+func inputOffset(dec *json.Decoder) int64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.14
+// +build go1.14
+
+package phperjson
+
+import "encoding/json"
+
+// hasInputOffset reports whether json.Decoder has the InputOffset method.
+const hasInputOffset = true
+
+func inputOffset(dec *json.Decoder) int64 {
+	return dec.InputOffset()
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.14
+// +build go1.14
+
+package phperjson
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeErrorPositionStream(t *testing.T) {
+	const in = "{\"a\":1}\n{\"a\":2}\n\n  {\"a\":\"x\"}\n{\"a\":\n\"y\"}"
+	for _, r := range []io.Reader{strings.NewReader(in), iotest.OneByteReader(strings.NewReader(in))} {
+		dec := NewDecoder(r)
+		dec.DisallowTypeJuggling()
+		var errs []*DecodeError
+		for dec.More() {
+			var v struct{ A int }
+			err := dec.Decode(&v)
+			if err == nil {
+				continue
+			}
+			decErr, ok := err.(*DecodeError)
+			if !ok {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			errs = append(errs, decErr)
+		}
+		if len(errs) != 2 {
+			t.Fatalf("want 2 errors, got %d", len(errs))
+		}
+		if e := errs[0]; e.Offset != 24 || e.Line != 4 || e.Column != 8 {
+			t.Errorf("unexpected position: offset %d, line %d, column %d", e.Offset, e.Line, e.Column)
+		}
+		if e := errs[1]; e.Offset != 35 || e.Line != 6 || e.Column != 1 {
+			t.Errorf("unexpected position: offset %d, line %d, column %d", e.Offset, e.Line, e.Column)
+		}
+		if e := errs[1].Err.(*UnmarshalTypeError); e.Offset != 35 {
+			t.Errorf("unexpected offset of UnmarshalTypeError: %d", e.Offset)
+		}
+	}
+}
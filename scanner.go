@@ -0,0 +1,61 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+var scannerType = reflect.TypeOf(new(sql.Scanner)).Elem()
+
+// scannerStore stores the JSON literal item into the sql.Scanner v, such as sql.NullInt64.
+// null is scanned as nil, so that Valid becomes false.
+//
+// For the types like sql.NullInt64, which have the value and the Valid field,
+// item is decoded into the type of the value with type juggling, and then scanned.
+// For other scanners, item is scanned as a string, an int64, a float64 or a bool.
+func (dec *Decoder) scannerStore(item []byte, v reflect.Value) error {
+	start := dec.off - len(item) // the offset of item in dec.data
+	vt := nullValueType(v.Type())
+	var value interface{}
+	switch c := item[0]; {
+	case c == 'n': // null
+	case vt != nil:
+		nv := reflect.New(vt).Elem()
+		if err := dec.literalStore(item, nv); err != nil {
+			return err
+		}
+		value = nv.Interface()
+	case c == 't' || c == 'f': // true, false
+		value = c == 't'
+	case c == '"': // string
+		s, ok := unquote(item)
+		if !ok {
+			return dec.withErrorContext(fmt.Errorf("phperjson: invalid string literal %s", item), start)
+		}
+		value = s
+	default: // number
+		value = phpNumber(string(item), 0)
+	}
+	if err := v.Addr().Interface().(sql.Scanner).Scan(value); err != nil {
+		return dec.withErrorContext(err, start)
+	}
+	return nil
+}
+
+// nullValueType returns the type of the value of the struct type t like sql.NullInt64,
+// which has the value and the Valid field.
+// It returns nil if t is not such a type.
+func nullValueType(t reflect.Type) reflect.Type {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 {
+		return nil
+	}
+	if f := t.Field(1); f.Name != "Valid" || f.Type.Kind() != reflect.Bool {
+		return nil
+	}
+	return t.Field(0).Type
+}
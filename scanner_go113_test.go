@@ -0,0 +1,37 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package phperjson
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScannerGo113(t *testing.T) {
+	var v struct {
+		Time  sql.NullTime
+		Int32 sql.NullInt32
+		Empty sql.NullTime
+	}
+	dec := NewDecoder(strings.NewReader(`{"Time":"2018-01-02T03:04:05Z","Int32":"7","Empty":""}`))
+	dec.SetNullLike(NullLikeEmptyString)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC); !v.Time.Valid || !v.Time.Time.Equal(want) {
+		t.Errorf("Time: got %#v, want %v", v.Time, want)
+	}
+	if want := (sql.NullInt32{Int32: 7, Valid: true}); v.Int32 != want {
+		t.Errorf("Int32: got %#v, want %#v", v.Int32, want)
+	}
+	if v.Empty.Valid {
+		t.Errorf("Empty: got %#v, want invalid", v.Empty)
+	}
+}
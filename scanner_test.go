@@ -0,0 +1,118 @@
+// Copyright 2018 Shogo Ichinose. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package phperjson
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scannerStatus is a sql.Scanner that is not a struct.
+type scannerStatus int
+
+func (s *scannerStatus) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*s = scannerStatus(v)
+	case string:
+		*s = scannerStatus(len(v))
+	case nil:
+		*s = -1
+	default:
+		return errors.New("unexpected type")
+	}
+	return nil
+}
+
+func TestScanner(t *testing.T) {
+	type row struct {
+		Int      sql.NullInt64
+		Float    sql.NullFloat64
+		String   sql.NullString
+		Bool     sql.NullBool
+		Ptr      *sql.NullInt64
+		Status   scannerStatus
+		Statuses []scannerStatus
+	}
+	tests := []struct {
+		in  string
+		out row
+	}{
+		{
+			in: `{"Int":42,"Float":1.5,"String":"foo","Bool":true,"Ptr":1,"Status":3,"Statuses":[1,"ab"]}`,
+			out: row{
+				Int:      sql.NullInt64{Int64: 42, Valid: true},
+				Float:    sql.NullFloat64{Float64: 1.5, Valid: true},
+				String:   sql.NullString{String: "foo", Valid: true},
+				Bool:     sql.NullBool{Bool: true, Valid: true},
+				Ptr:      &sql.NullInt64{Int64: 1, Valid: true},
+				Status:   3,
+				Statuses: []scannerStatus{1, 2},
+			},
+		},
+		{
+			// type juggling
+			in: `{"Int":"42","Float":"1.5","String":12,"Bool":"1"}`,
+			out: row{
+				Int:    sql.NullInt64{Int64: 42, Valid: true},
+				Float:  sql.NullFloat64{Float64: 1.5, Valid: true},
+				String: sql.NullString{String: "12", Valid: true},
+				Bool:   sql.NullBool{Bool: true, Valid: true},
+			},
+		},
+		{
+			in:  `{"Int":null,"Float":null,"String":null,"Bool":null,"Ptr":null,"Status":null}`,
+			out: row{Status: -1},
+		},
+		{
+			// objects are decoded into the fields, in the same way as encoding/json.
+			in:  `{"Int":{"Int64":7,"Valid":true}}`,
+			out: row{Int: sql.NullInt64{Int64: 7, Valid: true}},
+		},
+	}
+	for _, tt := range tests {
+		var got row
+		if err := Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("Unmarshal(%s): got %#v, want %#v", tt.in, got, tt.out)
+		}
+	}
+
+	// null makes the valid values invalid.
+	v := sql.NullString{String: "x", Valid: true}
+	if err := Unmarshal([]byte(`null`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v != (sql.NullString{}) {
+		t.Errorf("got %#v, want %#v", v, sql.NullString{})
+	}
+}
+
+func TestScannerError(t *testing.T) {
+	var v struct {
+		Int    sql.NullInt64
+		Status scannerStatus
+	}
+
+	// the settings of the decoder apply to the value.
+	dec := NewDecoder(strings.NewReader(`{"Int":"42"}`))
+	dec.DisallowTypeJuggling()
+	err := dec.Decode(&v)
+	if de, ok := err.(*DecodeError); !ok || de.Path != "Int" {
+		t.Errorf("got %#v, want *DecodeError", err)
+	}
+
+	// the errors of Scan
+	err = Unmarshal([]byte(`{"Status":true}`), &v)
+	if de, ok := err.(*DecodeError); !ok || de.Path != "Status" || de.Err.Error() != "unexpected type" {
+		t.Errorf("got %#v, want *DecodeError", err)
+	}
+}
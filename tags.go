@@ -42,3 +42,25 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// tagEncodeFlags is the encoder flags enabled by the options of the json tag.
+var tagEncodeFlags = []struct {
+	name string
+	flag EncodeFlag
+}{
+	{"forceobject", EncodeForceObject},
+	{"emptyarray", EncodeEmptyMapAsArray},
+	{"numericstring", encodeNumericString},
+	{"boolint", encodeBoolInt},
+}
+
+// encodeFlags returns the encoder flags that the options enable for the value of the field.
+func (o tagOptions) encodeFlags() EncodeFlag {
+	var flags EncodeFlag
+	for _, f := range tagEncodeFlags {
+		if o.Contains(f.name) {
+			flags |= f.flag
+		}
+	}
+	return flags
+}
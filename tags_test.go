@@ -26,3 +26,14 @@ func TestTagParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTagEncodeFlags(t *testing.T) {
+	_, opts := parseTag("field,omitempty,forceobject,boolint")
+	if got, want := opts.encodeFlags(), EncodeForceObject|encodeBoolInt; got != want {
+		t.Errorf("encodeFlags() = %d, want %d", got, want)
+	}
+	_, opts = parseTag("field,string")
+	if got := opts.encodeFlags(); got != 0 {
+		t.Errorf("encodeFlags() = %d, want 0", got)
+	}
+}